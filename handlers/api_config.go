@@ -1,7 +1,72 @@
 package handlers
 
-import "github.com/leguzman/rss-project/internal/database"
+import (
+	"context"
+	"database/sql"
+
+	"github.com/leguzman/rss-project/internal/auth"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/fetch"
+	"github.com/leguzman/rss-project/internal/ingest"
+	"github.com/leguzman/rss-project/internal/ratelimit"
+	"github.com/leguzman/rss-project/internal/websub"
+)
 
 type ApiConfig struct {
-	DB *database.Queries
+	DB   *database.Queries
+	Conn *sql.DB
+	// OIDC is nil unless single sign-on is configured.
+	OIDC *auth.OIDCProvider
+	// JWT is nil unless bearer tokens are accepted.
+	JWT *auth.JWTVerifier
+	// RateLimiter is nil when rate limiting is off.
+	RateLimiter *ratelimit.Limiter
+	// Signup is who may create an account, anyone when left empty.
+	Signup auth.SignupConfig
+	// TrustProxyHeaders believes the X-Forwarded-Proto and X-Forwarded-For
+	// or Forwarded headers, for servers behind a reverse proxy that sets
+	// them.
+	TrustProxyHeaders bool
+	// WebSub is nil unless hubs can reach the server to push feeds.
+	WebSub *websub.Subscriber
+	// Fetch makes the requests to URLs users give, with the defaults
+	// unless set.
+	Fetch *fetch.Client
+	// Ingester reads feeds and extracts their full content, built once at
+	// startup. Feeds are read with Fetch into DB, without extracting full
+	// content, unless set.
+	Ingester *ingest.Ingester
+}
+
+var defaultFetch = fetch.New(fetch.DefaultConfig())
+
+func (apiCfg *ApiConfig) fetchClient() *fetch.Client {
+	if apiCfg.Fetch != nil {
+		return apiCfg.Fetch
+	}
+	return defaultFetch
+}
+
+// ingester returns the Ingester built at startup, shared so its Extractor
+// bounds the extractions of every request. Without one, feeds are read with
+// Fetch into DB and full content isn't extracted.
+func (apiCfg *ApiConfig) ingester() *ingest.Ingester {
+	if apiCfg.Ingester != nil {
+		return apiCfg.Ingester
+	}
+	return ingest.New(ingest.NewHTTPFetcher(apiCfg.fetchClient()), ingest.DBSink{DB: apiCfg.DB})
+}
+
+// inTx runs fn with queries bound to a transaction, committed if fn succeeds.
+func (apiCfg *ApiConfig) inTx(ctx context.Context, fn func(*database.Queries) error) error {
+	tx, err := apiCfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = fn(apiCfg.DB.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
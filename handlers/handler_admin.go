@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/models"
+)
+
+func (apiCfg *ApiConfig) HandlerAdminGetUsers(w http.ResponseWriter, r *http.Request, user database.User) {
+	dbUsers, err := apiCfg.DB.ListUsers(r.Context())
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get users: %v", err))
+		return
+	}
+	users := []models.AdminUser{}
+	for _, dbUser := range dbUsers {
+		users = append(users, models.DBUserToAdminUser(dbUser))
+	}
+	respondWithJson(w, 200, WrappedSlice[models.AdminUser]{Results: users, Size: len(users)})
+}
+
+// HandlerAdminCreateUser creates a user whatever the signup mode, returning
+// their API key to hand over.
+func (apiCfg *ApiConfig) HandlerAdminCreateUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+		return
+	}
+	name, err := validateUserName(params.Name)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Invalid name: %v", err))
+		return
+	}
+	var created database.User
+	var key string
+	err = apiCfg.inTx(r.Context(), func(db *database.Queries) error {
+		created, key, err = createUser(r.Context(), db, name)
+		return err
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "Name already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Create user err: %v", err))
+		return
+	}
+	respondWithJson(w, 201, models.UserWithApiKey{User: models.DBUserToUser(created), APIKey: key})
+}
+
+// HandlerAdminUpdateUser disables or enables a user, and grants or revokes
+// their admin role. Admins can't do either to themselves, so there's always
+// one left.
+func (apiCfg *ApiConfig) HandlerAdminUpdateUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Disabled *bool `json:"disabled"`
+		IsAdmin  *bool `json:"is_admin"`
+	}
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse user id: %v", err))
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+		return
+	}
+	if userID == user.ID {
+		respondWithError(w, 400, "Admins can't disable or demote themselves")
+		return
+	}
+	var updated database.User
+	err = apiCfg.inTx(r.Context(), func(db *database.Queries) error {
+		var err error
+		updated, err = db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if params.Disabled != nil {
+			updated, err = db.SetUserDisabled(r.Context(), database.SetUserDisabledParams{
+				Disabled: *params.Disabled,
+				ID:       userID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if params.IsAdmin != nil {
+			updated, err = db.SetUserAdmin(r.Context(), database.SetUserAdminParams{
+				IsAdmin: *params.IsAdmin,
+				ID:      userID,
+			})
+		}
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't update user: %v", err))
+		return
+	}
+	respondWithJson(w, 200, models.DBUserToAdminUser(updated))
+}
+
+// HandlerAdminDeleteUser deletes a user the same way they can delete
+// themselves, keeping the feeds they created that others follow.
+func (apiCfg *ApiConfig) HandlerAdminDeleteUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse user id: %v", err))
+		return
+	}
+	if userID == user.ID {
+		respondWithError(w, 400, "Admins can't delete themselves")
+		return
+	}
+	err = apiCfg.inTx(r.Context(), func(db *database.Queries) error {
+		return deleteUser(r.Context(), db, userID)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't delete user: %v", err))
+		return
+	}
+	respondWithJson(w, 204, struct{}{})
+}
+
+// HandlerAdminGetFeeds lists every feed with how its fetches go, the failing
+// ones first. ?failing=true leaves out the healthy ones.
+func (apiCfg *ApiConfig) HandlerAdminGetFeeds(w http.ResponseWriter, r *http.Request, user database.User) {
+	onlyFailing := r.URL.Query().Get("failing") == "true"
+	dbFeeds, err := apiCfg.DB.GetFeedsWithHealth(r.Context(), onlyFailing)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get feeds: %v", err))
+		return
+	}
+	feeds := []models.FeedHealth{}
+	for _, dbFeed := range dbFeeds {
+		feeds = append(feeds, models.DBFeedHealthToFeedHealth(dbFeed))
+	}
+	respondWithJson(w, 200, WrappedSlice[models.FeedHealth]{Results: feeds, Size: len(feeds)})
+}
+
+// HandlerAdminRefreshFeed puts a feed first in line for the scraper, which
+// fetches it on its next tick.
+func (apiCfg *ApiConfig) HandlerAdminRefreshFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(chi.URLParam(r, "feedID"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse feed id: %v", err))
+		return
+	}
+	feed, err := apiCfg.DB.RefreshFeed(r.Context(), feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Feed not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't refresh feed: %v", err))
+		return
+	}
+	respondWithJson(w, 202, models.DBFeedToFeed(feed))
+}
+
+// HandlerAdminDeleteFeed deletes a feed with its posts and follows.
+func (apiCfg *ApiConfig) HandlerAdminDeleteFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(chi.URLParam(r, "feedID"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse feed id: %v", err))
+		return
+	}
+	_, err = apiCfg.DB.DeleteFeed(r.Context(), feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Feed not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't delete feed: %v", err))
+		return
+	}
+	respondWithJson(w, 204, struct{}{})
+}
+
+func (apiCfg *ApiConfig) HandlerAdminGetStats(w http.ResponseWriter, r *http.Request, user database.User) {
+	stats, err := apiCfg.DB.GetStats(r.Context())
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get stats: %v", err))
+		return
+	}
+	respondWithJson(w, 200, models.DBStatsToStats(stats))
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/auth"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/models"
+)
+
+const maxRotationGracePeriod = 7 * 24 * time.Hour
+
+func (apiCfg *ApiConfig) HandlerCreateApiKey(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+		return
+	}
+	if params.Name == "" {
+		respondWithError(w, 400, "An API key needs a name")
+		return
+	}
+	if params.Scopes == nil {
+		params.Scopes = []string{string(auth.ScopeRead)}
+	}
+	scopes, err := auth.ParseScopes(params.Scopes)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse scopes: %v", err))
+		return
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, 400, "An API key needs at least one scope")
+		return
+	}
+	held := ScopesFromContext(r.Context())
+	for _, scope := range scopes {
+		if !auth.HasScope(held, scope) {
+			respondWithError(w, 403, fmt.Sprintf("Can't grant the %s scope without holding it", scope))
+			return
+		}
+	}
+	apiKey, key, err := createApiKey(r.Context(), apiCfg.DB, user, params.Name, scopes)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Create API key err: %v", err))
+		return
+	}
+	respondWithJson(w, 201, models.CreatedApiKey{ApiKey: models.DBApiKeyToApiKey(apiKey), Key: key})
+}
+
+func (apiCfg *ApiConfig) HandlerGetApiKeys(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiKeys, err := apiCfg.DB.GetActiveApiKeys(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get API keys: %v", err))
+		return
+	}
+	response := WrappedSlice[models.ApiKey]{Results: models.DBApiKeysToApiKeys(apiKeys), Size: len(apiKeys)}
+	respondWithJson(w, 200, response)
+}
+
+// HandlerRotateApiKey replaces the key the request was made with by a new
+// one with the same name. The old key keeps working for the optional grace
+// period so clients can be switched over without downtime.
+func (apiCfg *ApiConfig) HandlerRotateApiKey(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		GracePeriodSeconds int `json:"grace_period_seconds"`
+	}
+	params := parameters{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&params)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+			return
+		}
+	}
+	gracePeriod := time.Duration(params.GracePeriodSeconds) * time.Second
+	if gracePeriod < 0 || gracePeriod > maxRotationGracePeriod {
+		respondWithError(w, 400, fmt.Sprintf("Grace period must be between 0 and %d seconds", int(maxRotationGracePeriod.Seconds())))
+		return
+	}
+	current, ok := ApiKeyFromContext(r.Context())
+	if !ok {
+		respondWithError(w, 400, "Only requests made with an API key can rotate it")
+		return
+	}
+	scopes, err := auth.ParseScopes(current.Scopes)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse scopes: %v", err))
+		return
+	}
+	var apiKey database.ApiKey
+	var key string
+	err = apiCfg.inTx(r.Context(), func(db *database.Queries) error {
+		var err error
+		apiKey, key, err = createApiKey(r.Context(), db, user, current.Name, scopes)
+		if err != nil {
+			return err
+		}
+		_, err = db.ExpireApiKey(r.Context(), database.ExpireApiKeyParams{
+			ExpiresAt: time.Now().UTC().Add(gracePeriod),
+			ID:        current.ID,
+			UserID:    user.ID,
+		})
+		return err
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't rotate API key: %v", err))
+		return
+	}
+	respondWithJson(w, 201, models.CreatedApiKey{ApiKey: models.DBApiKeyToApiKey(apiKey), Key: key})
+}
+
+func (apiCfg *ApiConfig) HandlerRevokeApiKey(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiKeyID, err := uuid.Parse(chi.URLParam(r, "apiKeyID"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse API key id: %v", err))
+		return
+	}
+	_, err = apiCfg.DB.RevokeApiKey(r.Context(), database.RevokeApiKeyParams{
+		ID:     apiKeyID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "API key not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't revoke API key: %v", err))
+		return
+	}
+	respondWithJson(w, 204, struct{}{})
+}
+
+// createApiKey mints a key for user holding scopes, returning the stored key
+// and its secret.
+func createApiKey(ctx context.Context, db *database.Queries, user database.User, name string, scopes []auth.Scope) (database.ApiKey, string, error) {
+	newKey, err := auth.GenerateApiKey()
+	if err != nil {
+		return database.ApiKey{}, "", err
+	}
+	apiKey, err := db.CreateApiKey(ctx, database.CreateApiKeyParams{
+		ID:           uuid.New(),
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
+		UserID:       user.ID,
+		Name:         name,
+		Prefix:       newKey.Prefix,
+		KeyHash:      newKey.Hash,
+		FeverKeyHash: auth.HashApiKey(auth.FeverKey(user.Name, newKey.Key)),
+		Scopes:       auth.ScopeStrings(scopes),
+	})
+	return apiKey, newKey.Key, err
+}
+
+// userFromApiKey looks up the owner of key, recording that the key was used.
+func (apiCfg *ApiConfig) userFromApiKey(ctx context.Context, key string) (database.User, database.ApiKey, error) {
+	apiKey, err := apiCfg.DB.UseApiKey(ctx, auth.HashApiKey(key))
+	if err != nil {
+		return database.User{}, database.ApiKey{}, err
+	}
+	user, err := apiCfg.activeUser(ctx, apiKey.UserID)
+	return user, apiKey, err
+}
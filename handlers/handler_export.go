@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/models"
+)
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title"`
+		DateCreated string `xml:"dateCreated"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []opmlOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type opmlOutline struct {
+	Type   string `xml:"type,attr"`
+	Text   string `xml:"text,attr"`
+	Title  string `xml:"title,attr"`
+	XMLURL string `xml:"xmlUrl,attr"`
+}
+
+// HandlerExportUser sends everything stored about the user as a zip archive:
+// their profile, follows, read and starred posts and the feeds they created,
+// plus their subscriptions as OPML for other readers to import.
+func (apiCfg *ApiConfig) HandlerExportUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	follows, err := apiCfg.DB.GetFeedFollows(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get feed follows: %v", err))
+		return
+	}
+	followedFeeds, err := apiCfg.DB.GetUserFollowedFeeds(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get followed feeds: %v", err))
+		return
+	}
+	dbPostStates, err := apiCfg.DB.GetUserPostStates(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get post states: %v", err))
+		return
+	}
+	createdFeeds, err := apiCfg.DB.GetUserCreatedFeeds(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get created feeds: %v", err))
+		return
+	}
+	postStates := []models.PostState{}
+	for _, dbPostState := range dbPostStates {
+		postStates = append(postStates, models.DBPostStateToPostState(dbPostState))
+	}
+	opml := opmlDocument{Version: "2.0"}
+	opml.Head.Title = fmt.Sprintf("Subscriptions of %s", user.Name)
+	opml.Head.DateCreated = time.Now().UTC().Format(time.RFC1123Z)
+	for _, feed := range followedFeeds {
+		opml.Body.Outlines = append(opml.Body.Outlines, opmlOutline{
+			Type:   "rss",
+			Text:   feed.Name,
+			Title:  feed.Name,
+			XMLURL: feed.Url,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.zip"`, time.Now().UTC().Format(time.DateOnly)))
+	w.WriteHeader(200)
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", models.DBUserToAdminUser(user)},
+		{"feed_follows.json", models.DBFeedFollowsToFeedFollows(follows)},
+		{"post_states.json", postStates},
+		{"created_feeds.json", models.DBFeedsToFeeds(createdFeeds)},
+		{"subscriptions.opml", opml},
+	}
+	for _, file := range files {
+		err = writeExportFile(archive, file.name, file.content)
+		if err != nil {
+			// The status is already sent, the client gets a truncated archive.
+			log.Printf("Couldn't export %s for user %s: %v", file.name, user.ID, err)
+			return
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		log.Printf("Couldn't export user %s: %v", user.ID, err)
+	}
+}
+
+func writeExportFile(archive *zip.Writer, name string, content interface{}) error {
+	f, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if opml, ok := content.(opmlDocument); ok {
+		_, err = f.Write([]byte(xml.Header))
+		if err != nil {
+			return err
+		}
+		encoder := xml.NewEncoder(f)
+		encoder.Indent("", "  ")
+		return encoder.Encode(opml)
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(content)
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -47,6 +49,40 @@ func (apiCfg *ApiConfig) HandlerGetFeedFollows(w http.ResponseWriter, r *http.Re
 	respondWithJson(w, 200, response)
 }
 
+// HandlerUpdateFeedFollow changes the settings of a follow, for now whether
+// new posts get the article from their linked page.
+func (apiCfg *ApiConfig) HandlerUpdateFeedFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		ExtractFullContent bool `json:"extract_full_content"`
+	}
+	feedFollowID, err := uuid.Parse(chi.URLParam(r, "feedFollowID"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse feed follow id: %v", err))
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+		return
+	}
+	feedFollow, err := apiCfg.DB.SetFeedFollowExtractFullContent(r.Context(), database.SetFeedFollowExtractFullContentParams{
+		ExtractFullContent: params.ExtractFullContent,
+		ID:                 feedFollowID,
+		UserID:             user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Feed follow not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't update feed follow: %v", err))
+		return
+	}
+	respondWithJson(w, 200, models.DBFeedFollowToFeedFollow(feedFollow))
+}
+
 func (apiCfg *ApiConfig) HandlerDeleteFeedFollow(w http.ResponseWriter, r *http.Request, user database.User) {
 	feedFellowIDStr := chi.URLParam(r, "feedFollowID")
 	feedFollowID, err := uuid.Parse(feedFellowIDStr)
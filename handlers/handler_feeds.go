@@ -8,6 +8,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/ingest"
+	"github.com/leguzman/rss-project/internal/sanitize"
 	"github.com/leguzman/rss-project/models"
 )
 
@@ -15,6 +17,9 @@ func (apiCfg *ApiConfig) HandlerCreateFeed(w http.ResponseWriter, r *http.Reques
 	type parameters struct {
 		Name string `json:"name"`
 		URL  string `json:"url"`
+		// Kind is "rss" when left empty, "scraped" needs ScrapeConfig.
+		Kind         string          `json:"kind"`
+		ScrapeConfig json.RawMessage `json:"scrape_config"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
@@ -23,14 +28,44 @@ func (apiCfg *ApiConfig) HandlerCreateFeed(w http.ResponseWriter, r *http.Reques
 		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
 		return
 	}
-	feed, err := apiCfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-		Name:      params.Name,
-		Url:       params.URL,
-		UserID:    user.ID,
-	})
+	var feed database.Feed
+	switch params.Kind {
+	case "", ingest.FeedKindRSS:
+		feed, err = apiCfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+			Name:      params.Name,
+			Url:       params.URL,
+			UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+		})
+	case ingest.FeedKindScraped:
+		var config ingest.ScrapeConfig
+		config, err = ingest.ParseScrapeConfig(params.ScrapeConfig)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Invalid scrape config: %v", err))
+			return
+		}
+		// Stored as parsed, without fields the scraper doesn't know.
+		var configJSON []byte
+		configJSON, err = json.Marshal(config)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Invalid scrape config: %v", err))
+			return
+		}
+		feed, err = apiCfg.DB.CreateScrapedFeed(r.Context(), database.CreateScrapedFeedParams{
+			ID:           uuid.New(),
+			CreatedAt:    time.Now().UTC(),
+			UpdatedAt:    time.Now().UTC(),
+			Name:         params.Name,
+			Url:          params.URL,
+			UserID:       uuid.NullUUID{UUID: user.ID, Valid: true},
+			ScrapeConfig: configJSON,
+		})
+	default:
+		respondWithError(w, 400, fmt.Sprintf("Unknown feed kind %q", params.Kind))
+		return
+	}
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Create user err: %v", err))
 		return
@@ -46,3 +81,92 @@ func (apiCfg *ApiConfig) HandlerGetFeeds(w http.ResponseWriter, r *http.Request)
 	response := WrappedSlice[models.Feed]{Results: models.DBFeedsToFeeds(feeds), Size: len(feeds)}
 	respondWithJson(w, 200, response)
 }
+
+// HandlerValidateFeed fetches a feed and parses it strictly, reporting what
+// the scraper, which repairs malformed feeds, would let through.
+func (apiCfg *ApiConfig) HandlerValidateFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		URL string `json:"url"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+		return
+	}
+	doc, err := apiCfg.ingester().Fetch(r.Context(), params.URL)
+	if err != nil {
+		respondWithError(w, 502, fmt.Sprintf("Couldn't fetch feed: %v", err))
+		return
+	}
+	validation := models.FeedValidation{Url: params.URL}
+	feed, err := ingest.ParseFeed(doc.Body, doc.ContentType)
+	if err == nil {
+		validation.Valid = true
+		validation.Recoverable = true
+		validation.Items = len(feed.Channel.Item)
+		respondWithJson(w, 200, validation)
+		return
+	}
+	message := err.Error()
+	validation.Error = &message
+	feed, _, err = ingest.ParseFeedLenient(doc.Body, doc.ContentType)
+	if err == nil {
+		validation.Recoverable = true
+		validation.Items = len(feed.Channel.Item)
+	}
+	respondWithJson(w, 200, validation)
+}
+
+// HandlerPreviewFeed shows the posts a feed would get without adding it, to
+// try out the selectors of a scraped feed.
+func (apiCfg *ApiConfig) HandlerPreviewFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		URL          string          `json:"url"`
+		Kind         string          `json:"kind"`
+		ScrapeConfig json.RawMessage `json:"scrape_config"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+		return
+	}
+	feed := ingest.Feed{URL: params.URL, Kind: params.Kind}
+	switch params.Kind {
+	case "":
+		feed.Kind = ingest.FeedKindRSS
+	case ingest.FeedKindRSS:
+	case ingest.FeedKindScraped:
+		_, err = ingest.ParseScrapeConfig(params.ScrapeConfig)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Invalid scrape config: %v", err))
+			return
+		}
+		feed.ScrapeConfig = params.ScrapeConfig
+	default:
+		respondWithError(w, 400, fmt.Sprintf("Unknown feed kind %q", params.Kind))
+		return
+	}
+	result, err := apiCfg.ingester().Read(r.Context(), feed)
+	if err != nil {
+		respondWithError(w, 502, fmt.Sprintf("Couldn't read feed: %v", err))
+		return
+	}
+	results := []models.FeedPreviewItem{}
+	for _, item := range result.Items {
+		base := item.Link
+		if base == "" {
+			base = params.URL
+		}
+		results = append(results, models.FeedPreviewItem{
+			Title:       item.Title,
+			Url:         item.Link,
+			Description: sanitize.HTML(item.Description, base),
+			PublishedAt: item.PublishedAt,
+		})
+	}
+	respondWithJson(w, 200, WrappedSlice[models.FeedPreviewItem]{Results: results, Size: len(results)})
+}
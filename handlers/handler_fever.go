@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leguzman/rss-project/internal/auth"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/models"
+)
+
+// feverGroupID is the single group every followed feed belongs to, since
+// feeds can't be organised into folders yet.
+const feverGroupID = 1
+
+type feverGroup struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int    `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverFeed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int64  `json:"favicon_id"`
+	Title             string `json:"title"`
+	Url               string `json:"url"`
+	SiteUrl           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type feverItem struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	Html          string `json:"html"`
+	Url           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+// HandlerFever implements the Fever API (https://feedafever.com/api), the
+// api_key being md5("<user name>:<api key>"), with the user name the account
+// had when the key was created.
+func (apiCfg *ApiConfig) HandlerFever(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"api_version": 3,
+		"auth":        0,
+	}
+	feverKey := strings.ToLower(r.FormValue("api_key"))
+	user, err := apiCfg.DB.GetUserByFeverKeyHash(r.Context(), auth.HashApiKey(feverKey))
+	if err != nil {
+		respondWithJson(w, 200, response)
+		return
+	}
+	response["auth"] = 1
+	response["last_refreshed_on_time"] = time.Now().Unix()
+
+	query := r.URL.Query()
+	if r.FormValue("mark") != "" {
+		err = apiCfg.feverMark(r, user)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Couldn't mark %s: %v", r.FormValue("mark"), err))
+			return
+		}
+	}
+	if query.Has("groups") || query.Has("feeds") {
+		feeds, err := apiCfg.DB.GetUserFollowedFeeds(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Couldn't get feeds: %v", err))
+			return
+		}
+		feedIDs := make([]string, 0, len(feeds))
+		feverFeeds := make([]feverFeed, 0, len(feeds))
+		for _, feed := range feeds {
+			feedIDs = append(feedIDs, strconv.FormatInt(feed.ShortID, 10))
+			feverFeeds = append(feverFeeds, feverFeed{
+				ID:                feed.ShortID,
+				Title:             feed.Name,
+				Url:               feed.Url,
+				SiteUrl:           feed.Url,
+				LastUpdatedOnTime: feed.LastFetchedAt.Time.Unix(),
+			})
+		}
+		feedsGroups := []feverFeedsGroup{{GroupID: feverGroupID, FeedIDs: strings.Join(feedIDs, ",")}}
+		if query.Has("groups") {
+			response["groups"] = []feverGroup{{ID: feverGroupID, Title: "All"}}
+		}
+		if query.Has("feeds") {
+			response["feeds"] = feverFeeds
+		}
+		response["feeds_groups"] = feedsGroups
+	}
+	if query.Has("favicons") {
+		response["favicons"] = []struct{}{}
+	}
+	if query.Has("links") {
+		response["links"] = []struct{}{}
+	}
+	if query.Has("items") {
+		params := database.GetUserPostItemsParams{
+			UserID:   user.ID,
+			ShortIds: []int64{},
+			RowLimit: 50,
+		}
+		params.SinceID, _ = strconv.ParseInt(query.Get("since_id"), 10, 64)
+		params.MaxID, _ = strconv.ParseInt(query.Get("max_id"), 10, 64)
+		if withIDs := query.Get("with_ids"); withIDs != "" {
+			params.ShortIds, err = parseFeverIDs(withIDs)
+			if err != nil {
+				respondWithError(w, 400, fmt.Sprintf("Couldn't parse with_ids: %v", err))
+				return
+			}
+		}
+		posts, err := apiCfg.DB.GetUserPostItems(r.Context(), params)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Couldn't get items: %v", err))
+			return
+		}
+		total, err := apiCfg.DB.CountUserPosts(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Couldn't count items: %v", err))
+			return
+		}
+		items := make([]feverItem, 0, len(posts))
+		for _, post := range posts {
+			items = append(items, feverItem{
+				ID:            post.ShortID,
+				FeedID:        post.FeedShortID,
+				Title:         post.Title,
+				Html:          models.DescriptionHTML(post.Description, post.DescriptionText, post.Url),
+				Url:           post.Url,
+				IsSaved:       feverBool(post.IsStarred),
+				IsRead:        feverBool(post.IsRead),
+				CreatedOnTime: post.PublishedAt.Unix(),
+			})
+		}
+		response["items"] = items
+		response["total_items"] = total
+	}
+	if query.Has("unread_item_ids") {
+		ids, err := apiCfg.DB.GetUserUnreadPostShortIDs(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Couldn't get unread items: %v", err))
+			return
+		}
+		response["unread_item_ids"] = joinFeverIDs(ids)
+	}
+	if query.Has("saved_item_ids") {
+		ids, err := apiCfg.DB.GetUserStarredPostShortIDs(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Couldn't get saved items: %v", err))
+			return
+		}
+		response["saved_item_ids"] = joinFeverIDs(ids)
+	}
+	respondWithJson(w, 200, response)
+}
+
+func (apiCfg *ApiConfig) feverMark(r *http.Request, user database.User) error {
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		return err
+	}
+	as := r.FormValue("as")
+	switch r.FormValue("mark") {
+	case "item":
+		switch as {
+		case "read", "unread":
+			return apiCfg.DB.SetPostRead(r.Context(), database.SetPostReadParams{
+				IsRead:  as == "read",
+				UserID:  user.ID,
+				ShortID: id,
+			})
+		case "saved", "unsaved":
+			return apiCfg.DB.SetPostStarred(r.Context(), database.SetPostStarredParams{
+				IsStarred: as == "saved",
+				UserID:    user.ID,
+				ShortID:   id,
+			})
+		}
+	case "feed", "group":
+		if as != "read" {
+			break
+		}
+		before := time.Now().UTC()
+		if beforeUnix, err := strconv.ParseInt(r.FormValue("before"), 10, 64); err == nil {
+			before = time.Unix(beforeUnix, 0).UTC()
+		}
+		params := database.MarkPostsReadParams{UserID: user.ID, Before: before}
+		if r.FormValue("mark") == "feed" {
+			// A FeedShortID of 0 would mark every feed.
+			if id <= 0 {
+				return fmt.Errorf("invalid feed id %d", id)
+			}
+			params.FeedShortID = id
+		} else if id != 0 && id != feverGroupID {
+			// Only Kindling (0) and the single group hold feeds, the
+			// others, like Sparks (-1), have nothing to mark.
+			return nil
+		}
+		return apiCfg.DB.MarkPostsRead(r.Context(), params)
+	}
+	return fmt.Errorf("unsupported action %q", as)
+}
+
+func parseFeverIDs(s string) ([]int64, error) {
+	ids := []int64{}
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func joinFeverIDs(ids []int64) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+func feverBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
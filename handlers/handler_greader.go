@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/auth"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/models"
+)
+
+const (
+	greaderItemPrefix   = "tag:google.com,2005:reader/item/"
+	greaderReadingList  = "user/-/state/com.google/reading-list"
+	greaderRead         = "user/-/state/com.google/read"
+	greaderStarred      = "user/-/state/com.google/starred"
+	greaderKeptUnread   = "user/-/state/com.google/kept-unread"
+	greaderFeedPrefix   = "feed/"
+	greaderMaxItems     = 1000
+	greaderDefaultItems = 20
+	greaderTokenTTL     = 7 * 24 * time.Hour
+)
+
+type greaderSubscription struct {
+	ID         string        `json:"id"`
+	Title      string        `json:"title"`
+	Categories []interface{} `json:"categories"`
+	Url        string        `json:"url"`
+	HtmlUrl    string        `json:"htmlUrl"`
+	IconUrl    string        `json:"iconUrl"`
+}
+
+type greaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderItem struct {
+	ID            string        `json:"id"`
+	CrawlTimeMsec string        `json:"crawlTimeMsec"`
+	TimestampUsec string        `json:"timestampUsec"`
+	Published     int64         `json:"published"`
+	Updated       int64         `json:"updated"`
+	Title         string        `json:"title"`
+	Canonical     []greaderLink `json:"canonical"`
+	Alternate     []greaderLink `json:"alternate"`
+	Summary       struct {
+		Content string `json:"content"`
+	} `json:"summary"`
+	Categories []string `json:"categories"`
+	Origin     struct {
+		StreamID string `json:"streamId"`
+		Title    string `json:"title"`
+		HtmlUrl  string `json:"htmlUrl"`
+	} `json:"origin"`
+	Author string `json:"author"`
+}
+
+type greaderItemRef struct {
+	ID              string   `json:"id"`
+	DirectStreamIDs []string `json:"directStreamIds"`
+	TimestampUsec   string   `json:"timestampUsec"`
+}
+
+// HandlerGReaderClientLogin exchanges a user name (Email) and API key (Passwd)
+// for the token Google Reader clients send on every subsequent request. The
+// token is minted for the key and expires, so the key itself never ends up in
+// client caches.
+func (apiCfg *ApiConfig) HandlerGReaderClientLogin(w http.ResponseWriter, r *http.Request) {
+	user, apiKey, err := apiCfg.userFromApiKey(r.Context(), r.FormValue("Passwd"))
+	if err != nil || user.Name != r.FormValue("Email") {
+		http.Error(w, "Error=BadAuthentication", 401)
+		return
+	}
+	token, err := auth.GenerateGReaderToken()
+	if err != nil {
+		http.Error(w, "Error=Unknown", 500)
+		return
+	}
+	now := time.Now().UTC()
+	err = apiCfg.DB.CreateGReaderToken(r.Context(), database.CreateGReaderTokenParams{
+		TokenHash: auth.HashApiKey(token),
+		ApiKeyID:  apiKey.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(greaderTokenTTL),
+	})
+	if err != nil {
+		http.Error(w, "Error=Unknown", 500)
+		return
+	}
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	fmt.Fprintf(w, "SID=%s\nLSID=null\nAuth=%s\n", token, token)
+}
+
+// userFromGReaderToken looks up the owner of a token handed out by
+// HandlerGReaderClientLogin, recording that its API key was used.
+func (apiCfg *ApiConfig) userFromGReaderToken(ctx context.Context, token string) (database.User, database.ApiKey, error) {
+	apiKey, err := apiCfg.DB.UseGReaderToken(ctx, auth.HashApiKey(token))
+	if err != nil {
+		return database.User{}, database.ApiKey{}, err
+	}
+	user, err := apiCfg.activeUser(ctx, apiKey.UserID)
+	return user, apiKey, err
+}
+
+func (apiCfg *ApiConfig) HandlerGReaderToken(w http.ResponseWriter, r *http.Request, user database.User) {
+	token, _ := auth.GetGoogleLoginToken(r.Header)
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	fmt.Fprintf(w, "%x\n", sha256.Sum256([]byte(token)))
+}
+
+func (apiCfg *ApiConfig) HandlerGReaderUserInfo(w http.ResponseWriter, r *http.Request, user database.User) {
+	respondWithJson(w, 200, map[string]string{
+		"userId":        user.ID.String(),
+		"userName":      user.Name,
+		"userProfileId": user.ID.String(),
+		"userEmail":     "",
+	})
+}
+
+func (apiCfg *ApiConfig) HandlerGReaderTagList(w http.ResponseWriter, r *http.Request, user database.User) {
+	respondWithJson(w, 200, map[string]interface{}{
+		"tags": []map[string]string{{"id": greaderStarred}},
+	})
+}
+
+func (apiCfg *ApiConfig) HandlerGReaderSubscriptionList(w http.ResponseWriter, r *http.Request, user database.User) {
+	feeds, err := apiCfg.DB.GetUserFollowedFeeds(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't get subscriptions: %v", err))
+		return
+	}
+	subscriptions := make([]greaderSubscription, 0, len(feeds))
+	for _, feed := range feeds {
+		subscriptions = append(subscriptions, greaderSubscription{
+			ID:         greaderFeedPrefix + strconv.FormatInt(feed.ShortID, 10),
+			Title:      feed.Name,
+			Categories: []interface{}{},
+			Url:        feed.Url,
+			HtmlUrl:    feed.Url,
+		})
+	}
+	respondWithJson(w, 200, map[string]interface{}{"subscriptions": subscriptions})
+}
+
+// HandlerGReaderSubscriptionEdit subscribes to ("ac=subscribe", s=feed/<url>)
+// or unsubscribes from ("ac=unsubscribe", s=feed/<id>) a feed by creating or
+// deleting the matching feed follow.
+func (apiCfg *ApiConfig) HandlerGReaderSubscriptionEdit(w http.ResponseWriter, r *http.Request, user database.User) {
+	r.ParseForm()
+	for _, streamID := range r.Form["s"] {
+		target, found := strings.CutPrefix(streamID, greaderFeedPrefix)
+		if !found {
+			respondWithError(w, 400, fmt.Sprintf("Unsupported stream %s", streamID))
+			return
+		}
+		switch r.FormValue("ac") {
+		case "subscribe":
+			feed, err := apiCfg.DB.GetFeedByUrl(r.Context(), target)
+			if errors.Is(err, sql.ErrNoRows) {
+				name := r.FormValue("t")
+				if name == "" {
+					name = target
+				}
+				feed, err = apiCfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
+					ID:        uuid.New(),
+					CreatedAt: time.Now().UTC(),
+					UpdatedAt: time.Now().UTC(),
+					Name:      name,
+					Url:       target,
+					UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+				})
+			}
+			if err != nil {
+				respondWithError(w, 400, fmt.Sprintf("Couldn't get feed: %v", err))
+				return
+			}
+			_, err = apiCfg.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+				ID:        uuid.New(),
+				CreatedAt: time.Now().UTC(),
+				UpdatedAt: time.Now().UTC(),
+				UserID:    user.ID,
+				FeedID:    feed.ID,
+			})
+			if err != nil && !strings.Contains(err.Error(), "duplicate key") {
+				respondWithError(w, 400, fmt.Sprintf("Create FeedFollow err: %v", err))
+				return
+			}
+		case "unsubscribe":
+			feedShortID, err := strconv.ParseInt(target, 10, 64)
+			if err != nil {
+				respondWithError(w, 400, fmt.Sprintf("Couldn't parse feed id: %v", err))
+				return
+			}
+			err = apiCfg.DB.DeleteFeedFollowByFeedShortID(r.Context(), database.DeleteFeedFollowByFeedShortIDParams{
+				UserID:  user.ID,
+				ShortID: feedShortID,
+			})
+			if err != nil {
+				respondWithError(w, 400, fmt.Sprintf("Couldn't delete feed follow: %v", err))
+				return
+			}
+		case "edit":
+			// Feeds are shared between users, so renaming or moving them is ignored.
+		default:
+			respondWithError(w, 400, fmt.Sprintf("Unsupported action %s", r.FormValue("ac")))
+			return
+		}
+	}
+	respondWithGReaderOK(w)
+}
+
+func (apiCfg *ApiConfig) HandlerGReaderUnreadCount(w http.ResponseWriter, r *http.Request, user database.User) {
+	counts, err := apiCfg.DB.GetUserUnreadCounts(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't get unread counts: %v", err))
+		return
+	}
+	type unreadCount struct {
+		ID                      string `json:"id"`
+		Count                   int64  `json:"count"`
+		NewestItemTimestampUsec string `json:"newestItemTimestampUsec"`
+	}
+	total := unreadCount{ID: greaderReadingList}
+	newest := time.Time{}
+	unreadCounts := []unreadCount{}
+	for _, count := range counts {
+		unreadCounts = append(unreadCounts, unreadCount{
+			ID:                      greaderFeedPrefix + strconv.FormatInt(count.FeedShortID, 10),
+			Count:                   count.Count,
+			NewestItemTimestampUsec: strconv.FormatInt(count.NewestPublishedAt.UnixMicro(), 10),
+		})
+		total.Count += count.Count
+		if count.NewestPublishedAt.After(newest) {
+			newest = count.NewestPublishedAt
+		}
+	}
+	total.NewestItemTimestampUsec = strconv.FormatInt(newest.UnixMicro(), 10)
+	unreadCounts = append(unreadCounts, total)
+	respondWithJson(w, 200, map[string]interface{}{"max": greaderMaxItems, "unreadcounts": unreadCounts})
+}
+
+// HandlerGReaderStreamContents lists the items of the stream in the URL, e.g.
+// /stream/contents/user/-/state/com.google/reading-list or /stream/contents/feed/12.
+func (apiCfg *ApiConfig) HandlerGReaderStreamContents(w http.ResponseWriter, r *http.Request, user database.User) {
+	streamID, err := url.PathUnescape(chi.URLParam(r, "*"))
+	if err != nil || streamID == "" {
+		streamID = r.FormValue("s")
+	}
+	params, err := greaderStreamParams(r, user, streamID)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	posts, err := apiCfg.DB.GetUserStreamItems(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't get items: %v", err))
+		return
+	}
+	response := map[string]interface{}{
+		"id":      streamID,
+		"updated": time.Now().Unix(),
+		"items":   greaderItems(posts),
+	}
+	if len(posts) == int(params.RowLimit) {
+		response["continuation"] = strconv.FormatInt(posts[len(posts)-1].ShortID, 10)
+	}
+	respondWithJson(w, 200, response)
+}
+
+func (apiCfg *ApiConfig) HandlerGReaderStreamItemIDs(w http.ResponseWriter, r *http.Request, user database.User) {
+	params, err := greaderStreamParams(r, user, r.FormValue("s"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	posts, err := apiCfg.DB.GetUserStreamItems(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't get items: %v", err))
+		return
+	}
+	itemRefs := make([]greaderItemRef, 0, len(posts))
+	for _, post := range posts {
+		itemRefs = append(itemRefs, greaderItemRef{
+			ID:              strconv.FormatInt(post.ShortID, 10),
+			DirectStreamIDs: []string{},
+			TimestampUsec:   strconv.FormatInt(post.PublishedAt.UnixMicro(), 10),
+		})
+	}
+	response := map[string]interface{}{"itemRefs": itemRefs}
+	if len(posts) == int(params.RowLimit) {
+		response["continuation"] = strconv.FormatInt(posts[len(posts)-1].ShortID, 10)
+	}
+	respondWithJson(w, 200, response)
+}
+
+func (apiCfg *ApiConfig) HandlerGReaderStreamItemContents(w http.ResponseWriter, r *http.Request, user database.User) {
+	r.ParseForm()
+	ids, err := parseGReaderItemIDs(r.Form["i"])
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	posts := []database.GetUserStreamItemsRow{}
+	if len(ids) > 0 {
+		posts, err = apiCfg.DB.GetUserStreamItems(r.Context(), database.GetUserStreamItemsParams{
+			UserID:   user.ID,
+			ShortIds: ids,
+			RowLimit: int32(len(ids)),
+		})
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Couldn't get items: %v", err))
+			return
+		}
+	}
+	respondWithJson(w, 200, map[string]interface{}{
+		"id":      greaderReadingList,
+		"updated": time.Now().Unix(),
+		"items":   greaderItems(posts),
+	})
+}
+
+// HandlerGReaderEditTag adds (a=) or removes (r=) the read and starred states
+// on the items listed in i=.
+func (apiCfg *ApiConfig) HandlerGReaderEditTag(w http.ResponseWriter, r *http.Request, user database.User) {
+	r.ParseForm()
+	ids, err := parseGReaderItemIDs(r.Form["i"])
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	for _, id := range ids {
+		for _, tag := range r.Form["a"] {
+			err = apiCfg.setGReaderTag(r, user, id, tag, true)
+			if err != nil {
+				respondWithError(w, 400, fmt.Sprintf("Couldn't add tag %s: %v", tag, err))
+				return
+			}
+		}
+		for _, tag := range r.Form["r"] {
+			err = apiCfg.setGReaderTag(r, user, id, tag, false)
+			if err != nil {
+				respondWithError(w, 400, fmt.Sprintf("Couldn't remove tag %s: %v", tag, err))
+				return
+			}
+		}
+	}
+	respondWithGReaderOK(w)
+}
+
+func (apiCfg *ApiConfig) HandlerGReaderMarkAllAsRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	params := database.MarkPostsReadParams{UserID: user.ID, Before: time.Now().UTC()}
+	if tsUsec, err := strconv.ParseInt(r.FormValue("ts"), 10, 64); err == nil && tsUsec > 0 {
+		params.Before = time.UnixMicro(tsUsec).UTC()
+	}
+	streamID := r.FormValue("s")
+	if target, found := strings.CutPrefix(streamID, greaderFeedPrefix); found {
+		feedShortID, err := strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Couldn't parse feed id: %v", err))
+			return
+		}
+		params.FeedShortID = feedShortID
+	} else if streamID != greaderReadingList {
+		respondWithError(w, 400, fmt.Sprintf("Unsupported stream %s", streamID))
+		return
+	}
+	err := apiCfg.DB.MarkPostsRead(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't mark as read: %v", err))
+		return
+	}
+	respondWithGReaderOK(w)
+}
+
+func (apiCfg *ApiConfig) setGReaderTag(r *http.Request, user database.User, id int64, tag string, add bool) error {
+	switch greaderNormalizeTag(tag) {
+	case greaderRead:
+		return apiCfg.DB.SetPostRead(r.Context(), database.SetPostReadParams{IsRead: add, UserID: user.ID, ShortID: id})
+	case greaderKeptUnread:
+		return apiCfg.DB.SetPostRead(r.Context(), database.SetPostReadParams{IsRead: !add, UserID: user.ID, ShortID: id})
+	case greaderStarred:
+		return apiCfg.DB.SetPostStarred(r.Context(), database.SetPostStarredParams{IsStarred: add, UserID: user.ID, ShortID: id})
+	}
+	return fmt.Errorf("unsupported tag")
+}
+
+func greaderStreamParams(r *http.Request, user database.User, streamID string) (database.GetUserStreamItemsParams, error) {
+	params := database.GetUserStreamItemsParams{
+		UserID:      user.ID,
+		ShortIds:    []int64{},
+		RowLimit:    greaderDefaultItems,
+		OldestFirst: r.FormValue("r") == "o",
+	}
+	switch greaderNormalizeTag(streamID) {
+	case greaderReadingList:
+	case greaderRead:
+		params.OnlyRead = true
+	case greaderStarred:
+		params.OnlyStarred = true
+	default:
+		target, found := strings.CutPrefix(streamID, greaderFeedPrefix)
+		if !found {
+			return params, fmt.Errorf("unsupported stream %s", streamID)
+		}
+		feedShortID, err := strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			return params, fmt.Errorf("couldn't parse feed id: %v", err)
+		}
+		params.FeedShortID = feedShortID
+	}
+	if greaderNormalizeTag(r.FormValue("xt")) == greaderRead {
+		params.OnlyUnread = true
+	}
+	if n, err := strconv.Atoi(r.FormValue("n")); err == nil && n > 0 {
+		params.RowLimit = int32(min(n, greaderMaxItems))
+	}
+	if ot, err := strconv.ParseInt(r.FormValue("ot"), 10, 64); err == nil && ot > 0 {
+		params.NewerThan = time.Unix(ot, 0).UTC()
+	}
+	if nt, err := strconv.ParseInt(r.FormValue("nt"), 10, 64); err == nil && nt > 0 {
+		params.OlderThan = time.Unix(nt, 0).UTC()
+	}
+	params.Continuation, _ = strconv.ParseInt(r.FormValue("c"), 10, 64)
+	return params, nil
+}
+
+func greaderItems(posts []database.GetUserStreamItemsRow) []greaderItem {
+	items := make([]greaderItem, 0, len(posts))
+	for _, post := range posts {
+		item := greaderItem{
+			ID:            fmt.Sprintf("%s%016x", greaderItemPrefix, post.ShortID),
+			CrawlTimeMsec: strconv.FormatInt(post.CreatedAt.UnixMilli(), 10),
+			TimestampUsec: strconv.FormatInt(post.PublishedAt.UnixMicro(), 10),
+			Published:     post.PublishedAt.Unix(),
+			Updated:       post.UpdatedAt.Unix(),
+			Title:         post.Title,
+			Canonical:     []greaderLink{{Href: post.Url}},
+			Alternate:     []greaderLink{{Href: post.Url, Type: "text/html"}},
+			Categories:    []string{greaderReadingList},
+		}
+		item.Summary.Content = models.DescriptionHTML(post.Description, post.DescriptionText, post.Url)
+		item.Origin.StreamID = greaderFeedPrefix + strconv.FormatInt(post.FeedShortID, 10)
+		item.Origin.Title = post.FeedName
+		item.Origin.HtmlUrl = post.FeedUrl
+		if post.IsRead {
+			item.Categories = append(item.Categories, greaderRead)
+		}
+		if post.IsStarred {
+			item.Categories = append(item.Categories, greaderStarred)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// parseGReaderItemIDs accepts both the long form
+// (tag:google.com,2005:reader/item/<16 hex digits>) and the decimal short form,
+// up to greaderMaxItems of them.
+func parseGReaderItemIDs(values []string) ([]int64, error) {
+	if len(values) > greaderMaxItems {
+		return nil, fmt.Errorf("at most %d items can be given at once", greaderMaxItems)
+	}
+	ids := []int64{}
+	for _, value := range values {
+		if hex, found := strings.CutPrefix(value, greaderItemPrefix); found {
+			id, err := strconv.ParseUint(hex, 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't parse item id %s: %v", value, err)
+			}
+			ids = append(ids, int64(id))
+			continue
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse item id %s: %v", value, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// greaderNormalizeTag replaces the user id some clients send in place of "-".
+func greaderNormalizeTag(tag string) string {
+	if rest, found := strings.CutPrefix(tag, "user/"); found {
+		if i := strings.Index(rest, "/"); i >= 0 {
+			return "user/-" + rest[i:]
+		}
+	}
+	return tag
+}
+
+func respondWithGReaderOK(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte("OK"))
+}
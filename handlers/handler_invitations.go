@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/auth"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/models"
+)
+
+const invitationDuration = 7 * 24 * time.Hour
+
+// HandlerAdminCreateInvitation creates a single use invitation to sign up
+// with when the signup mode is invite. It expires after a week unless
+// expires_at says otherwise.
+func (apiCfg *ApiConfig) HandlerAdminCreateInvitation(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	params := parameters{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&params)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+			return
+		}
+	}
+	expiresAt := time.Now().UTC().Add(invitationDuration)
+	if params.ExpiresAt != nil {
+		if !params.ExpiresAt.After(time.Now()) {
+			respondWithError(w, 400, "expires_at must be in the future")
+			return
+		}
+		expiresAt = params.ExpiresAt.UTC()
+	}
+	newInvitation, err := auth.GenerateInvitation()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't create invitation: %v", err))
+		return
+	}
+	invitation, err := apiCfg.DB.CreateInvitation(r.Context(), database.CreateInvitationParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: uuid.NullUUID{UUID: user.ID, Valid: true},
+		CodeHash:  newInvitation.Hash,
+		ExpiresAt: sql.NullTime{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't create invitation: %v", err))
+		return
+	}
+	respondWithJson(w, 201, models.CreatedInvitation{
+		Invitation: models.DBInvitationToInvitation(invitation),
+		Code:       newInvitation.Code,
+	})
+}
+
+func (apiCfg *ApiConfig) HandlerAdminGetInvitations(w http.ResponseWriter, r *http.Request, user database.User) {
+	dbInvitations, err := apiCfg.DB.GetInvitations(r.Context())
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get invitations: %v", err))
+		return
+	}
+	invitations := []models.Invitation{}
+	for _, dbInvitation := range dbInvitations {
+		invitations = append(invitations, models.DBInvitationToInvitation(dbInvitation))
+	}
+	respondWithJson(w, 200, WrappedSlice[models.Invitation]{Results: invitations, Size: len(invitations)})
+}
+
+func (apiCfg *ApiConfig) HandlerAdminDeleteInvitation(w http.ResponseWriter, r *http.Request, user database.User) {
+	invitationID, err := uuid.Parse(chi.URLParam(r, "invitationID"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse invitation id: %v", err))
+		return
+	}
+	_, err = apiCfg.DB.DeleteInvitation(r.Context(), invitationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Invitation not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't delete invitation: %v", err))
+		return
+	}
+	respondWithJson(w, 204, struct{}{})
+}
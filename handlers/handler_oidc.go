@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/auth"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/models"
+)
+
+const (
+	oidcLoginCookieName = "oidc_login"
+	oidcLoginCookiePath = "/v1/auth/oidc"
+	oidcLoginTimeout    = 10 * time.Minute
+)
+
+// HandlerOIDCLogin sends the browser to the identity provider, remembering
+// the state, nonce and PKCE verifier of the login in a short-lived cookie.
+func (apiCfg *ApiConfig) HandlerOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if apiCfg.OIDC == nil {
+		respondWithError(w, 404, "Single sign-on isn't configured")
+		return
+	}
+	url, err := apiCfg.startOIDC(w, r, "")
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't start single sign-on: %v", err))
+		return
+	}
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// HandlerOIDCLink starts linking the identity the browser signs in with at the
+// identity provider to the account of user, who can then log in with it. It
+// changes what the session may do, so it's a POST the session must send its
+// CSRF token with, and returns the URL for the front-end to send the browser
+// to. The callback must come back with the same browser session, so API keys
+// and bearer tokens can't start it.
+func (apiCfg *ApiConfig) HandlerOIDCLink(w http.ResponseWriter, r *http.Request, user database.User) {
+	if apiCfg.OIDC == nil {
+		respondWithError(w, 404, "Single sign-on isn't configured")
+		return
+	}
+	if _, ok := SessionFromContext(r.Context()); !ok {
+		respondWithError(w, 400, "Linking needs a browser session, log in with a password first")
+		return
+	}
+	url, err := apiCfg.startOIDC(w, r, user.ID.String())
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't start single sign-on: %v", err))
+		return
+	}
+	respondWithJson(w, 200, models.OIDCLink{AuthorizationURL: url})
+}
+
+// startOIDC sets the cookie of a login, linking to linkUserID unless it's
+// empty, and returns the URL of the identity provider to send the browser to.
+func (apiCfg *ApiConfig) startOIDC(w http.ResponseWriter, r *http.Request, linkUserID string) (string, error) {
+	url, login, err := apiCfg.OIDC.AuthCodeURL()
+	if err != nil {
+		return "", err
+	}
+	login.LinkUserID = linkUserID
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookieName,
+		Value:    login.Encode(),
+		Path:     oidcLoginCookiePath,
+		MaxAge:   int(oidcLoginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   apiCfg.requestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return url, nil
+}
+
+// HandlerOIDCCallback is where the identity provider sends the browser back
+// to. It starts a session for the user the identity belongs to, creating one
+// on the first login, or finishes linking it started by HandlerOIDCLink.
+func (apiCfg *ApiConfig) HandlerOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if apiCfg.OIDC == nil {
+		respondWithError(w, 404, "Single sign-on isn't configured")
+		return
+	}
+	cookie, err := r.Cookie(oidcLoginCookieName)
+	if err != nil {
+		respondWithError(w, 400, "No single sign-on in progress")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookieName,
+		Path:     oidcLoginCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   apiCfg.requestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	login, err := auth.DecodeOIDCLogin(cookie.Value)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't read single sign-on: %v", err))
+		return
+	}
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		respondWithError(w, 401, fmt.Sprintf("Single sign-on failed: %s %s", errCode, query.Get("error_description")))
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(login.State)) != 1 {
+		respondWithError(w, 400, "Single sign-on state doesn't match")
+		return
+	}
+	identity, err := apiCfg.OIDC.Exchange(r.Context(), query.Get("code"), login)
+	if err != nil {
+		respondWithError(w, 401, fmt.Sprintf("Single sign-on failed: %v", err))
+		return
+	}
+	if login.LinkUserID != "" {
+		apiCfg.linkOIDC(w, r, login.LinkUserID, identity)
+		return
+	}
+	user, err := apiCfg.oidcUser(r.Context(), identity)
+	if errors.Is(err, errSignupClosed) {
+		respondWithError(w, 403, "No account for this identity and signup is closed")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get user: %v", err))
+		return
+	}
+	if user.DisabledAt.Valid {
+		respondWithError(w, 403, "Account disabled")
+		return
+	}
+	session, err := apiCfg.startSession(w, r, user)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't create session: %v", err))
+		return
+	}
+	if apiCfg.OIDC.PostLoginURL != "" {
+		http.Redirect(w, r, apiCfg.OIDC.PostLoginURL, http.StatusSeeOther)
+		return
+	}
+	respondWithJson(w, 201, session)
+}
+
+// linkOIDC links identity to the user who started linking, checking they
+// are still the one logged in on this browser.
+func (apiCfg *ApiConfig) linkOIDC(w http.ResponseWriter, r *http.Request, linkUserID string, identity auth.OIDCIdentity) {
+	token, err := auth.GetSessionToken(r)
+	if err != nil {
+		respondWithError(w, 401, "Auth error: log in again to link single sign-on")
+		return
+	}
+	session, err := apiCfg.DB.UseSession(r.Context(), auth.HashApiKey(token))
+	if err != nil || session.UserID.String() != linkUserID {
+		respondWithError(w, 401, "Auth error: log in again to link single sign-on")
+		return
+	}
+	issuer := sql.NullString{String: identity.Issuer, Valid: true}
+	subject := sql.NullString{String: identity.Subject, Valid: true}
+	var user database.User
+	err = apiCfg.inTx(r.Context(), func(db *database.Queries) error {
+		linked, err := db.GetUserByOIDCSubject(r.Context(), database.GetUserByOIDCSubjectParams{
+			OidcIssuer:  issuer,
+			OidcSubject: subject,
+		})
+		if err == nil && linked.ID != session.UserID {
+			return errOIDCLinked
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		user, err = db.GetUserByID(r.Context(), session.UserID)
+		if err != nil {
+			return err
+		}
+		email := user.Email
+		if identity.EmailVerified && identity.Email != "" {
+			email = sql.NullString{String: identity.Email, Valid: true}
+		}
+		user, err = db.LinkUserOIDC(r.Context(), database.LinkUserOIDCParams{
+			OidcIssuer:  issuer,
+			OidcSubject: subject,
+			Email:       email,
+			ID:          session.UserID,
+		})
+		return err
+	})
+	if errors.Is(err, errOIDCLinked) || isUniqueViolation(err) {
+		respondWithError(w, 409, "This identity or its email belongs to another account")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't link single sign-on: %v", err))
+		return
+	}
+	if apiCfg.OIDC.PostLoginURL != "" {
+		http.Redirect(w, r, apiCfg.OIDC.PostLoginURL, http.StatusSeeOther)
+		return
+	}
+	respondWithJson(w, 200, models.DBUserToUser(user))
+}
+
+var (
+	errSignupClosed = errors.New("signup is closed")
+	errOIDCLinked   = errors.New("identity is linked to another account")
+)
+
+// oidcUser returns the user identity signed in as before, or creates one on
+// its first login if the signup mode is open. Existing accounts get an
+// identity through HandlerOIDCLink instead, an email alone isn't trusted to
+// tell whose account it is.
+func (apiCfg *ApiConfig) oidcUser(ctx context.Context, identity auth.OIDCIdentity) (database.User, error) {
+	issuer := sql.NullString{String: identity.Issuer, Valid: true}
+	subject := sql.NullString{String: identity.Subject, Valid: true}
+	email := sql.NullString{String: identity.Email, Valid: identity.EmailVerified && identity.Email != ""}
+	var user database.User
+	err := apiCfg.inTx(ctx, func(db *database.Queries) error {
+		var err error
+		user, err = db.GetUserByOIDCSubject(ctx, database.GetUserByOIDCSubjectParams{
+			OidcIssuer:  issuer,
+			OidcSubject: subject,
+		})
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if apiCfg.Signup.Mode == auth.SignupInvite || apiCfg.Signup.Mode == auth.SignupAdmin {
+			return errSignupClosed
+		}
+		name, err := oidcUserName(ctx, db, identity.Name)
+		if err != nil {
+			return err
+		}
+		feedToken, err := auth.GenerateFeedToken()
+		if err != nil {
+			return err
+		}
+		user, err = db.CreateOIDCUser(ctx, database.CreateOIDCUserParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now().UTC(),
+			UpdatedAt:   time.Now().UTC(),
+			Name:        name,
+			Email:       email,
+			OidcIssuer:  issuer,
+			OidcSubject: subject,
+			FeedToken:   feedToken,
+		})
+		return err
+	})
+	return user, err
+}
+
+// oidcUserName picks the name of a user created through single sign-on,
+// suffixing the name from the identity provider if it's taken.
+func oidcUserName(ctx context.Context, db *database.Queries, name string) (string, error) {
+	name, err := validateUserName(name)
+	if err != nil {
+		name = "user"
+	}
+	_, err = db.GetUserByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return name, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	suffix := "-" + uuid.New().String()[:8]
+	runes := []rune(name)
+	if len(runes)+len(suffix) > maxUserNameLength {
+		name = string(runes[:maxUserNameLength-len(suffix)])
+	}
+	return name + suffix, nil
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/extract"
+	"github.com/leguzman/rss-project/models"
+)
+
+// HandlerExtractPost fetches the linked page of a post again and replaces its
+// extracted article, for when the page changed or the first try picked the
+// wrong part of it. Only followers with full content turned on may do so.
+func (apiCfg *ApiConfig) HandlerExtractPost(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, err := uuid.Parse(chi.URLParam(r, "postID"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse post id: %v", err))
+		return
+	}
+	post, err := apiCfg.DB.GetUserPost(r.Context(), database.GetUserPostParams{
+		ID:     postID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Post not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get post: %v", err))
+		return
+	}
+	// The extraction is shared by every follower, only those who asked for
+	// full content may replace it.
+	wantsFullContent, err := apiCfg.DB.UserWantsFullContent(r.Context(), database.UserWantsFullContentParams{
+		FeedID: post.FeedID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't check full content setting: %v", err))
+		return
+	}
+	if !wantsFullContent {
+		respondWithError(w, 403, "Full content isn't enabled for this feed")
+		return
+	}
+	article, err := extract.Extract(r.Context(), apiCfg.fetchClient(), post.Url)
+	if err != nil {
+		respondWithError(w, 502, fmt.Sprintf("Couldn't extract article: %v", err))
+		return
+	}
+	post, err = apiCfg.DB.SetPostExtractedContent(r.Context(), database.SetPostExtractedContentParams{
+		ExtractedContent: sql.NullString{String: article.Content, Valid: true},
+		ID:               post.ID,
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't store extracted article: %v", err))
+		return
+	}
+	results := []models.Post{models.DBPostToPost(post)}
+	err = apiCfg.addPostDetails(r.Context(), results)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get post details: %v", err))
+		return
+	}
+	respondWithJson(w, 200, results[0])
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/auth"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/models"
+)
+
+const sessionDuration = 30 * 24 * time.Hour
+
+// HandlerSetPassword lets a user log in from a browser with their name and
+// password. Changing an existing password requires the current one.
+func (apiCfg *ApiConfig) HandlerSetPassword(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Password        string `json:"password"`
+		CurrentPassword string `json:"current_password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+		return
+	}
+	if user.PasswordHash.Valid && !auth.CheckPassword(user.PasswordHash.String, params.CurrentPassword) {
+		respondWithError(w, 403, "Current password is incorrect")
+		return
+	}
+	// Users log in by name, which must then point to a single account.
+	other, err := apiCfg.DB.GetUserByNameWithPassword(r.Context(), user.Name)
+	if err == nil && other.ID != user.ID {
+		respondWithError(w, 409, "Another user with this name already has a password")
+		return
+	}
+	hash, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't set password: %v", err))
+		return
+	}
+	_, err = apiCfg.DB.SetUserPassword(r.Context(), database.SetUserPasswordParams{
+		PasswordHash: sql.NullString{String: hash, Valid: true},
+		ID:           user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't set password: %v", err))
+		return
+	}
+	respondWithJson(w, 204, struct{}{})
+}
+
+// HandlerLogin starts a session for browser clients, kept in an HttpOnly
+// cookie. The CSRF token is both returned and set in a cookie scripts can
+// read, and must be echoed in the X-CSRF-Token header of unsafe requests.
+func (apiCfg *ApiConfig) HandlerLogin(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+		return
+	}
+	user, err := apiCfg.DB.GetUserByNameWithPassword(r.Context(), params.Name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't get user: %v", err))
+		return
+	}
+	if !auth.CheckPassword(user.PasswordHash.String, params.Password) {
+		respondWithError(w, 401, "Invalid name or password")
+		return
+	}
+	if user.DisabledAt.Valid {
+		respondWithError(w, 403, "Account disabled")
+		return
+	}
+	session, err := apiCfg.startSession(w, r, user)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't create session: %v", err))
+		return
+	}
+	respondWithJson(w, 201, session)
+}
+
+// startSession creates a session for user and sets its cookies.
+func (apiCfg *ApiConfig) startSession(w http.ResponseWriter, r *http.Request, user database.User) (models.CreatedSession, error) {
+	newSession, err := auth.GenerateSession()
+	if err != nil {
+		return models.CreatedSession{}, err
+	}
+	session, err := apiCfg.DB.CreateSession(r.Context(), database.CreateSessionParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UserID:    user.ID,
+		TokenHash: newSession.Hash,
+		CsrfToken: newSession.CSRFToken,
+		ExpiresAt: time.Now().UTC().Add(sessionDuration),
+		UserAgent: r.UserAgent(),
+		Ip:        apiCfg.clientIP(r),
+	})
+	if err != nil {
+		return models.CreatedSession{}, err
+	}
+	apiCfg.setSessionCookies(w, r, newSession.Token, newSession.CSRFToken, session.ExpiresAt)
+	created := models.CreatedSession{
+		Session:   models.DBSessionToSession(session),
+		User:      models.DBUserToUser(user),
+		CSRFToken: newSession.CSRFToken,
+	}
+	created.Current = true
+	return created, nil
+}
+
+func (apiCfg *ApiConfig) HandlerLogout(w http.ResponseWriter, r *http.Request, user database.User) {
+	session, ok := SessionFromContext(r.Context())
+	if !ok {
+		respondWithError(w, 400, "Only requests made with a session can log out")
+		return
+	}
+	_, err := apiCfg.DB.DeleteSession(r.Context(), database.DeleteSessionParams{
+		ID:     session.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't log out: %v", err))
+		return
+	}
+	apiCfg.clearSessionCookies(w, r)
+	respondWithJson(w, 204, struct{}{})
+}
+
+func (apiCfg *ApiConfig) HandlerGetSessions(w http.ResponseWriter, r *http.Request, user database.User) {
+	dbSessions, err := apiCfg.DB.GetUserSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get sessions: %v", err))
+		return
+	}
+	current, _ := SessionFromContext(r.Context())
+	sessions := []models.Session{}
+	for _, dbSession := range dbSessions {
+		session := models.DBSessionToSession(dbSession)
+		session.Current = dbSession.ID == current.ID
+		sessions = append(sessions, session)
+	}
+	response := WrappedSlice[models.Session]{Results: sessions, Size: len(sessions)}
+	respondWithJson(w, 200, response)
+}
+
+func (apiCfg *ApiConfig) HandlerRevokeSession(w http.ResponseWriter, r *http.Request, user database.User) {
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse session id: %v", err))
+		return
+	}
+	_, err = apiCfg.DB.DeleteSession(r.Context(), database.DeleteSessionParams{
+		ID:     sessionID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Session not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't revoke session: %v", err))
+		return
+	}
+	if current, ok := SessionFromContext(r.Context()); ok && current.ID == sessionID {
+		apiCfg.clearSessionCookies(w, r)
+	}
+	respondWithJson(w, 204, struct{}{})
+}
+
+// setSessionCookies sets cookies only sent back over HTTPS when the request
+// came that way, so logging in works on a plain HTTP local setup.
+func (apiCfg *ApiConfig) setSessionCookies(w http.ResponseWriter, r *http.Request, token, csrfToken string, expires time.Time) {
+	secure := apiCfg.requestScheme(r) == "https"
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.CSRFCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (apiCfg *ApiConfig) clearSessionCookies(w http.ResponseWriter, r *http.Request) {
+	secure := apiCfg.requestScheme(r) == "https"
+	for _, name := range []string{auth.SessionCookieName, auth.CSRFCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == auth.SessionCookieName,
+			Secure:   secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// clientIP is the address the request came from, without its port. Behind a
+// trusted reverse proxy, it's the rightmost address of X-Forwarded-For, or of
+// Forwarded, that isn't on a private network: the proxies in between are,
+// and the addresses left of it could have been made up by the client. When
+// every hop is private, the one the proxy saw is taken.
+func (apiCfg *ApiConfig) clientIP(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		peer = host
+	}
+	if !apiCfg.TrustProxyHeaders {
+		return peer
+	}
+	hops := forwardedFor(r.Header)
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := parseHop(hops[i])
+		if err != nil {
+			break
+		}
+		if client == "" {
+			client = addr.String()
+		}
+		if !addr.IsPrivate() && !addr.IsLoopback() {
+			return addr.String()
+		}
+	}
+	if client == "" {
+		return peer
+	}
+	return client
+}
+
+// forwardedFor lists the addresses of X-Forwarded-For, or else the for
+// parameters of Forwarded (RFC 7239), from the client to the last proxy.
+func forwardedFor(header http.Header) []string {
+	hops := []string{}
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	if len(hops) > 0 {
+		return hops
+	}
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					hops = append(hops, value)
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop parses a forwarded address, which may be quoted, bracketed or
+// come with a port.
+func parseHop(hop string) (netip.Addr, error) {
+	hop = strings.Trim(strings.TrimSpace(hop), `"`)
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(strings.Trim(hop, "[]"))
+	return addr.Unmap(), err
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leguzman/rss-project/internal/auth"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/models"
+)
+
+type rssOutput struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Atom    string   `xml:"xmlns:atom,attr"`
+	Channel struct {
+		Title         string `xml:"title"`
+		Link          string `xml:"link"`
+		Description   string `xml:"description"`
+		LastBuildDate string `xml:"lastBuildDate"`
+		SelfLink      struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"atom:link"`
+		Item []rssOutputItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssOutputItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description,omitempty"`
+	PubDate     string `xml:"pubDate"`
+	GUID        struct {
+		IsPermaLink bool   `xml:"isPermaLink,attr"`
+		Value       string `xml:",chardata"`
+	} `xml:"guid"`
+}
+
+type atomOutput struct {
+	XMLName xml.Name         `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string           `xml:"id"`
+	Title   string           `xml:"title"`
+	Updated string           `xml:"updated"`
+	Link    []atomOutputLink `xml:"link"`
+	// Author stands for the entries without authors of their own.
+	Author atomOutputAuthor  `xml:"author"`
+	Entry  []atomOutputEntry `xml:"entry"`
+}
+
+type atomOutputAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomOutputLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomOutputText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomOutputEntry struct {
+	ID        string             `xml:"id"`
+	Title     string             `xml:"title"`
+	Link      atomOutputLink     `xml:"link"`
+	Published string             `xml:"published"`
+	Updated   string             `xml:"updated"`
+	Summary   *atomOutputText    `xml:"summary,omitempty"`
+	Author    []atomOutputAuthor `xml:"author"`
+}
+
+type jsonFeedOutput struct {
+	Version     string                 `json:"version"`
+	Title       string                 `json:"title"`
+	HomePageURL string                 `json:"home_page_url"`
+	FeedURL     string                 `json:"feed_url"`
+	Authors     []jsonFeedOutputAuthor `json:"authors"`
+	Items       []jsonFeedOutputItem   `json:"items"`
+}
+
+type jsonFeedOutputAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedOutputItem struct {
+	ID            string                 `json:"id"`
+	URL           string                 `json:"url"`
+	Title         string                 `json:"title"`
+	ContentHTML   string                 `json:"content_html,omitempty"`
+	DatePublished string                 `json:"date_published"`
+	Authors       []jsonFeedOutputAuthor `json:"authors,omitempty"`
+}
+
+// HandlerGetTimeline renders the posts of the user owning feedToken as an
+// RSS 2.0, Atom or JSON Feed document, so it can be consumed by other readers.
+func (apiCfg *ApiConfig) HandlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	user, err := apiCfg.DB.GetUserByFeedToken(r.Context(), chi.URLParam(r, "feedToken"))
+	if err != nil {
+		respondWithError(w, 404, "Timeline not found")
+		return
+	}
+	posts, err := apiCfg.DB.GetUserPosts(r.Context(), database.GetUserPostsParams{
+		UserID:   user.ID,
+		RowLimit: pageSize(r),
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't get posts: %v", err))
+		return
+	}
+
+	title := fmt.Sprintf("%s's timeline", user.Name)
+	baseURL := apiCfg.requestBaseURL(r)
+	selfURL := baseURL + r.URL.Path
+	updated := user.UpdatedAt
+	for _, post := range posts {
+		if post.PublishedAt.After(updated) {
+			updated = post.PublishedAt
+		}
+	}
+
+	switch chi.URLParam(r, "format") {
+	case "rss":
+		feed := rssOutput{Version: "2.0", Atom: "http://www.w3.org/2005/Atom"}
+		feed.Channel.Title = title
+		feed.Channel.Link = baseURL
+		feed.Channel.Description = title
+		feed.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+		feed.Channel.SelfLink.Href = selfURL
+		feed.Channel.SelfLink.Rel = "self"
+		feed.Channel.SelfLink.Type = "application/rss+xml"
+		for _, post := range models.DBPostsToPosts(posts) {
+			item := rssOutputItem{
+				Title:       post.Title,
+				Link:        post.Url,
+				Description: post.Description,
+				PubDate:     post.PublishedAt.Format(time.RFC1123Z),
+			}
+			item.GUID.Value = "urn:uuid:" + post.ID.String()
+			feed.Channel.Item = append(feed.Channel.Item, item)
+		}
+		respondWithXML(w, 200, "application/rss+xml; charset=utf-8", feed)
+	case "atom":
+		feed := atomOutput{
+			ID:      "urn:uuid:" + user.ID.String(),
+			Title:   title,
+			Updated: updated.Format(time.RFC3339),
+			Link:    []atomOutputLink{{Href: selfURL, Rel: "self"}},
+			Author:  atomOutputAuthor{Name: user.Name},
+		}
+		for _, post := range models.DBPostsToPosts(posts) {
+			entry := atomOutputEntry{
+				ID:        "urn:uuid:" + post.ID.String(),
+				Title:     post.Title,
+				Link:      atomOutputLink{Href: post.Url, Rel: "alternate"},
+				Published: post.PublishedAt.Format(time.RFC3339),
+				Updated:   post.UpdatedAt.Format(time.RFC3339),
+			}
+			for _, author := range post.Authors {
+				entry.Author = append(entry.Author, atomOutputAuthor{Name: author})
+			}
+			if post.Description != "" {
+				entry.Summary = &atomOutputText{Type: "html", Value: post.Description}
+			}
+			feed.Entry = append(feed.Entry, entry)
+		}
+		respondWithXML(w, 200, "application/atom+xml; charset=utf-8", feed)
+	case "json":
+		feed := jsonFeedOutput{
+			Version:     "https://jsonfeed.org/version/1.1",
+			Title:       title,
+			HomePageURL: baseURL,
+			FeedURL:     selfURL,
+			Authors:     []jsonFeedOutputAuthor{{Name: user.Name}},
+			Items:       []jsonFeedOutputItem{},
+		}
+		for _, post := range models.DBPostsToPosts(posts) {
+			item := jsonFeedOutputItem{
+				ID:            post.ID.String(),
+				URL:           post.Url,
+				Title:         post.Title,
+				ContentHTML:   post.Description,
+				DatePublished: post.PublishedAt.Format(time.RFC3339),
+			}
+			for _, author := range post.Authors {
+				item.Authors = append(item.Authors, jsonFeedOutputAuthor{Name: author})
+			}
+			feed.Items = append(feed.Items, item)
+		}
+		data, err := json.Marshal(feed)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Couldn't render feed: %v", err))
+			return
+		}
+		w.Header().Add("Content-Type", "application/feed+json; charset=utf-8")
+		w.WriteHeader(200)
+		w.Write(data)
+	default:
+		respondWithError(w, 404, "Unknown timeline format, expected rss, atom or json")
+	}
+}
+
+func (apiCfg *ApiConfig) HandlerRegenerateFeedToken(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedToken, err := auth.GenerateFeedToken()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't generate feed token: %v", err))
+		return
+	}
+	user, err = apiCfg.DB.RegenerateFeedToken(r.Context(), database.RegenerateFeedTokenParams{
+		ID:        user.ID,
+		FeedToken: feedToken,
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't regenerate feed token: %v", err))
+		return
+	}
+	respondWithJson(w, 200, models.DBUserToUser(user))
+}
+
+func (apiCfg *ApiConfig) requestBaseURL(r *http.Request) string {
+	return apiCfg.requestScheme(r) + "://" + r.Host
+}
+
+// requestScheme is the scheme the client used, which is only known from
+// X-Forwarded-Proto behind a reverse proxy terminating TLS.
+func (apiCfg *ApiConfig) requestScheme(r *http.Request) string {
+	if apiCfg.TrustProxyHeaders {
+		switch proto := r.Header.Get("X-Forwarded-Proto"); proto {
+		case "http", "https":
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -1,21 +1,46 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/auth"
 	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/postfilter"
 	"github.com/leguzman/rss-project/models"
+	"github.com/lib/pq"
 )
 
+const (
+	maxUserNameLength       = 64
+	signupChallengeDuration = 10 * time.Minute
+)
+
+var (
+	errInvalidInvitation = errors.New("invalid or expired invitation")
+	errInvalidChallenge  = errors.New("unknown or expired challenge")
+)
+
+// HandlerCreateUser signs up a new user as the signup mode allows: anyone, only
+// with an invitation or nobody, leaving it to admins. Proof of work may be
+// required on top, solving a challenge from HandlerCreateSignupChallenge.
 func (apiCfg *ApiConfig) HandlerCreateUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Name string `json:"name"`
+		Name       string `json:"name"`
+		Invitation string `json:"invitation"`
+		Challenge  string `json:"challenge"`
+		Nonce      string `json:"nonce"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
@@ -24,17 +49,143 @@ func (apiCfg *ApiConfig) HandlerCreateUser(w http.ResponseWriter, r *http.Reques
 		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
 		return
 	}
-	user, err := apiCfg.DB.CreateUser(r.Context(), database.CreateUserParams{
+	switch apiCfg.Signup.Mode {
+	case auth.SignupAdmin:
+		respondWithError(w, 403, "Signup is closed, ask an admin for an account")
+		return
+	case auth.SignupInvite:
+		if params.Invitation == "" {
+			respondWithError(w, 403, "Signup requires an invitation")
+			return
+		}
+	}
+	name, err := validateUserName(params.Name)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Invalid name: %v", err))
+		return
+	}
+	if difficulty := apiCfg.Signup.ProofOfWorkBits; difficulty > 0 {
+		if !auth.CheckProofOfWork(params.Challenge, params.Nonce, difficulty) {
+			respondWithError(w, 403, "Missing or insufficient proof of work")
+			return
+		}
+	}
+	var user database.User
+	var key string
+	err = apiCfg.inTx(r.Context(), func(db *database.Queries) error {
+		// The challenge is only used up if the user gets created.
+		if apiCfg.Signup.ProofOfWorkBits > 0 {
+			_, err := db.UseSignupChallenge(r.Context(), params.Challenge)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errInvalidChallenge
+			}
+			if err != nil {
+				return err
+			}
+		}
+		user, key, err = createUser(r.Context(), db, name)
+		if err != nil || apiCfg.Signup.Mode != auth.SignupInvite {
+			return err
+		}
+		_, err = db.UseInvitation(r.Context(), database.UseInvitationParams{
+			CodeHash: auth.HashApiKey(params.Invitation),
+			UsedBy:   uuid.NullUUID{UUID: user.ID, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidInvitation
+		}
+		return err
+	})
+	if errors.Is(err, errInvalidChallenge) {
+		respondWithError(w, 403, "Unknown or expired challenge")
+		return
+	}
+	if errors.Is(err, errInvalidInvitation) {
+		respondWithError(w, 403, "Invalid or expired invitation")
+		return
+	}
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "Name already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Create user err: %v", err))
+		return
+	}
+	respondWithJson(w, 201, models.UserWithApiKey{User: models.DBUserToUser(user), APIKey: key})
+}
+
+// HandlerCreateSignupChallenge hands out a challenge to prove work on before
+// signing up, when the server asks for it.
+func (apiCfg *ApiConfig) HandlerCreateSignupChallenge(w http.ResponseWriter, r *http.Request) {
+	if apiCfg.Signup.ProofOfWorkBits == 0 {
+		respondWithError(w, 404, "Signup doesn't require proof of work")
+		return
+	}
+	challenge, err := auth.GenerateChallenge()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't create challenge: %v", err))
+		return
+	}
+	expiresAt := time.Now().UTC().Add(signupChallengeDuration)
+	err = apiCfg.DB.CreateSignupChallenge(r.Context(), database.CreateSignupChallengeParams{
+		Challenge: challenge,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't create challenge: %v", err))
+		return
+	}
+	respondWithJson(w, 201, models.SignupChallenge{
+		Challenge:  challenge,
+		Difficulty: apiCfg.Signup.ProofOfWorkBits,
+		ExpiresAt:  expiresAt,
+	})
+}
+
+// createUser creates a user along with a default API key allowed everything,
+// returned in clear.
+func createUser(ctx context.Context, db *database.Queries, name string) (database.User, string, error) {
+	feedToken, err := auth.GenerateFeedToken()
+	if err != nil {
+		return database.User{}, "", err
+	}
+	user, err := db.CreateUser(ctx, database.CreateUserParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now().UTC(),
 		UpdatedAt: time.Now().UTC(),
-		Name:      params.Name,
+		Name:      name,
+		FeedToken: feedToken,
 	})
 	if err != nil {
-		respondWithError(w, 400, fmt.Sprintf("Create user err: %v", err))
-		return
+		return database.User{}, "", err
 	}
-	respondWithJson(w, 201, models.DBUserToUser(user))
+	_, key, err := createApiKey(ctx, db, user, "default", auth.AllScopes)
+	return user, key, err
+}
+
+// validateUserName trims name and checks it is fit to be shown and logged in
+// with.
+func validateUserName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("can't be empty")
+	}
+	if utf8.RuneCountInString(name) > maxUserNameLength {
+		return "", fmt.Errorf("can't be longer than %d characters", maxUserNameLength)
+	}
+	for _, c := range name {
+		if !unicode.IsPrint(c) {
+			return "", errors.New("can only contain printable characters")
+		}
+	}
+	return name, nil
+}
+
+// isUniqueViolation reports whether err comes from a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
 }
 
 func (apiCfg *ApiConfig) HandlerGetUser(w http.ResponseWriter, r *http.Request, user database.User) {
@@ -42,29 +193,75 @@ func (apiCfg *ApiConfig) HandlerGetUser(w http.ResponseWriter, r *http.Request,
 }
 
 func (apiCfg *ApiConfig) HandlerGetUserPosts(w http.ResponseWriter, r *http.Request, user database.User) {
-	limitStr := r.URL.Query().Get("limit")
-	limit, err := strconv.Atoi(limitStr)
+	limit := pageSize(r)
+	cursor, err := decodeCursor(r)
 	if err != nil {
-		limit = 100
+		respondWithError(w, 400, err.Error())
+		return
 	}
-	posts, err := apiCfg.DB.GetUserPosts(r.Context(), database.GetUserPostsParams{
-		UserID: user.ID,
-		Limit:  int32(limit),
-	})
+	params := database.GetUserPostsParams{
+		UserID:   user.ID,
+		RowLimit: limit + 1,
+	}
+	if cursor != nil {
+		if cursor.Sort != defaultPostSort || len(cursor.Keys) != 1 {
+			respondWithError(w, 400, "cursor doesn't match sort")
+			return
+		}
+		publishedAt, ok := cursor.Keys[0].(string)
+		params.CursorPublishedAt, err = time.Parse(time.RFC3339Nano, publishedAt)
+		if !ok || err != nil {
+			respondWithError(w, 400, "malformed cursor")
+			return
+		}
+		params.HasCursor = true
+		params.CursorID = cursor.ID
+		params.Backward = cursor.Backward
+	}
+	posts, err := apiCfg.DB.GetUserPosts(r.Context(), params)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Couldn't get posts: %v", err))
 		return
 	}
-	response := WrappedSlice[models.Post]{Results: models.DBPostsToPosts(posts), Size: len(posts)}
+	if params.Backward {
+		slices.Reverse(posts)
+	}
+	posts, next, prev := pageCursors(posts, limit, cursor, func(post database.Post) postfilter.Cursor {
+		return postfilter.Cursor{Sort: defaultPostSort, Keys: []interface{}{post.PublishedAt}, ID: post.ID}
+	})
+	results := models.DBPostsToPosts(posts)
+	err = apiCfg.addPostDetails(r.Context(), results)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get post details: %v", err))
+		return
+	}
+	response := WrappedSlice[models.Post]{
+		Results:    results,
+		Size:       len(results),
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	if wantsTotal(r) {
+		total, err := apiCfg.DB.CountUserPosts(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Couldn't count posts: %v", err))
+			return
+		}
+		response.Total = &total
+	}
 	respondWithJson(w, 200, response)
 }
 
 func (apiCfg *ApiConfig) HandlerFilterUserPosts(w http.ResponseWriter, r *http.Request, user database.User) {
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
 	description := r.URL.Query().Get("description")
 	title := r.URL.Query().Get("title")
-	sortColumn := r.URL.Query().Get("sortColumn")
+	author := r.URL.Query().Get("author")
+	category := r.URL.Query().Get("category")
+	search := r.URL.Query().Get("q")
+	sortParam := r.URL.Query().Get("sort")
+	if sortParam == "" {
+		sortParam = r.URL.Query().Get("sortColumn")
+	}
 	before, err := time.Parse(time.DateOnly, r.URL.Query().Get("before"))
 	if err != nil {
 		log.Printf("Error parsing before date: %s", err)
@@ -75,31 +272,170 @@ func (apiCfg *ApiConfig) HandlerFilterUserPosts(w http.ResponseWriter, r *http.R
 		log.Printf("Error parsing after date: %s", err)
 		after = time.Time{}
 	}
-	limit, err := strconv.Atoi(limitStr)
+	limit := pageSize(r)
+	sort, err := parsePostSort(sortParam)
 	if err != nil {
-		limit = 100
+		respondWithError(w, 400, fmt.Sprintf("Couldn't parse sort: %v", err))
+		return
+	}
+	sortKey := formatPostSort(sort)
+	if search == "" && strings.Contains(sortKey, "relevance") {
+		respondWithError(w, 400, "Sorting by relevance requires a search query (q)")
+		return
 	}
-	offset, err := strconv.Atoi(offsetStr)
+	cursor, err := decodeCursor(r)
 	if err != nil {
-		offset = 0
+		respondWithError(w, 400, err.Error())
+		return
 	}
-	posts, err := apiCfg.DB.FilterUserPosts(r.Context(), database.FilterUserPostsParams{
-		UserID:          user.ID,
-		Description:     description,
-		Title:           title,
-		Before:          before,
-		After:           after,
-		TitleAsc:        sortColumn == " title",
-		TitleDesc:       sortColumn == "-title",
-		DescriptionAsc:  sortColumn == " description",
-		DescriptionDesc: sortColumn == "-description",
-		Limit:           int32(limit),
-		Offset:          int32(offset),
-	})
+	if cursor != nil {
+		if cursor.Sort != sortKey {
+			respondWithError(w, 400, "cursor doesn't match sort")
+			return
+		}
+		_, err = cursor.Values(sort)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+	}
+	params := postfilter.Params{
+		UserID:      user.ID,
+		Description: description,
+		Title:       title,
+		Author:      author,
+		Category:    category,
+		Query:       search,
+		Before:      before,
+		After:       after,
+		Sort:        sort,
+		Cursor:      cursor,
+		Limit:       limit + 1,
+	}
+	posts, err := postfilter.Posts(r.Context(), apiCfg.Conn, params)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Couldn't get posts: %v", err))
 		return
 	}
-	response := WrappedSlice[models.Post]{Results: models.DBPostsToPosts(posts), Size: len(posts)}
+	posts, next, prev := pageCursors(posts, limit, cursor, func(post postfilter.Row) postfilter.Cursor {
+		return postfilter.Cursor{Sort: sortKey, Keys: post.SortKeys, ID: post.ID}
+	})
+	results := []models.Post{}
+	for _, post := range posts {
+		results = append(results, models.DBPostToPost(post.Post))
+	}
+	err = apiCfg.addPostDetails(r.Context(), results)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get post details: %v", err))
+		return
+	}
+	response := WrappedSlice[models.Post]{
+		Results:    results,
+		Size:       len(results),
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	if wantsTotal(r) {
+		total, err := postfilter.Count(r.Context(), apiCfg.Conn, params)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Couldn't count posts: %v", err))
+			return
+		}
+		response.Total = &total
+	}
+	respondWithJson(w, 200, response)
+}
+
+// HandlerUpdateUser renames the user. Fever keys are derived from the name a
+// key was created under and can't be derived again without the key, so
+// Fever clients keep signing in with the old name until keys are rotated,
+// which the response warns about.
+func (apiCfg *ApiConfig) HandlerUpdateUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error parsing json: %v", err))
+		return
+	}
+	name, err := validateUserName(params.Name)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Invalid name: %v", err))
+		return
+	}
+	updated, err := apiCfg.DB.UpdateUserName(r.Context(), database.UpdateUserNameParams{
+		Name: name,
+		ID:   user.ID,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "Name already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't update user: %v", err))
+		return
+	}
+	response := models.UpdatedUser{User: models.DBUserToUser(updated)}
+	if updated.Name != user.Name {
+		response.Warning = fmt.Sprintf("Fever clients must sign in as %q until API keys are rotated", user.Name)
+	}
 	respondWithJson(w, 200, response)
 }
+
+// HandlerDeleteUser deletes the account of the user making the request.
+func (apiCfg *ApiConfig) HandlerDeleteUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	err := apiCfg.inTx(r.Context(), func(db *database.Queries) error {
+		return deleteUser(r.Context(), db, user.ID)
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't delete user: %v", err))
+		return
+	}
+	apiCfg.clearSessionCookies(w, r)
+	respondWithJson(w, 204, struct{}{})
+}
+
+// deleteUser deletes a user with their keys, sessions, follows and post
+// states. The feeds they created go too unless someone else follows them, in
+// which case they stay without an owner.
+func deleteUser(ctx context.Context, db *database.Queries, id uuid.UUID) error {
+	err := db.DeleteUnfollowedUserFeeds(ctx, uuid.NullUUID{UUID: id, Valid: true})
+	if err != nil {
+		return err
+	}
+	_, err = db.DeleteUser(ctx, id)
+	return err
+}
+
+// addPostDetails fills in the categories and attachments of posts.
+func (apiCfg *ApiConfig) addPostDetails(ctx context.Context, posts []models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(posts))
+	byID := map[uuid.UUID]*models.Post{}
+	for i := range posts {
+		ids = append(ids, posts[i].ID)
+		byID[posts[i].ID] = &posts[i]
+	}
+	categories, err := apiCfg.DB.GetPostsCategories(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		post := byID[category.PostID]
+		post.Categories = append(post.Categories, category.Name)
+	}
+	attachments, err := apiCfg.DB.GetPostsAttachments(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		post := byID[attachment.PostID]
+		post.Attachments = append(post.Attachments, models.DBPostAttachmentToAttachment(attachment))
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/ingest"
+	"github.com/leguzman/rss-project/internal/websub"
+)
+
+// maxPushSize is the largest content a hub may push, feeds past it are
+// left to polling.
+const maxPushSize = 5 << 20
+
+// webSubSubscription finds the subscription a hub calls back for, answering
+// 404 itself when there is none.
+func (apiCfg *ApiConfig) webSubSubscription(w http.ResponseWriter, r *http.Request) (database.WebsubSubscription, bool) {
+	if apiCfg.WebSub == nil {
+		respondWithError(w, 404, "WebSub is not enabled")
+		return database.WebsubSubscription{}, false
+	}
+	feedID, err := uuid.Parse(chi.URLParam(r, "feedID"))
+	if err != nil {
+		respondWithError(w, 404, "Unknown subscription")
+		return database.WebsubSubscription{}, false
+	}
+	subscription, err := apiCfg.DB.GetWebSubSubscription(r.Context(), feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Unknown subscription")
+		return database.WebsubSubscription{}, false
+	}
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't get subscription: %v", err))
+		return database.WebsubSubscription{}, false
+	}
+	return subscription, true
+}
+
+// HandlerWebSubVerify answers a hub checking that a subscription was asked
+// for by echoing its challenge, or takes note that the hub refused it.
+func (apiCfg *ApiConfig) HandlerWebSubVerify(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := apiCfg.webSubSubscription(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("hub.topic") != subscription.Topic {
+		respondWithError(w, 404, "Unknown topic")
+		return
+	}
+	switch query.Get("hub.mode") {
+	case "subscribe":
+		if !webSubAwaitingVerification(subscription) {
+			respondWithError(w, 404, "No subscription request to verify")
+			return
+		}
+		// Hubs may grant less than the lease asked for, not more.
+		leaseSeconds := apiCfg.WebSub.LeaseSeconds()
+		if n, err := strconv.Atoi(query.Get("hub.lease_seconds")); err == nil && n > 0 {
+			leaseSeconds = min(n, leaseSeconds)
+		}
+		_, err := apiCfg.DB.ActivateWebSubSubscription(r.Context(), database.ActivateWebSubSubscriptionParams{
+			FeedID: subscription.FeedID,
+			LeaseExpiresAt: sql.NullTime{
+				Time:  time.Now().UTC().Add(time.Duration(leaseSeconds) * time.Second),
+				Valid: true,
+			},
+		})
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Couldn't activate subscription: %v", err))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(200)
+		w.Write([]byte(query.Get("hub.challenge")))
+	case "denied":
+		if subscription.State != "pending" || !webSubAwaitingVerification(subscription) {
+			respondWithError(w, 404, "No subscription request to deny")
+			return
+		}
+		log.Printf("Hub %s denied subscription to %s: %s", subscription.Hub, subscription.Topic, query.Get("hub.reason"))
+		err := apiCfg.DB.DenyWebSubSubscription(r.Context(), subscription.FeedID)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Couldn't record denial: %v", err))
+			return
+		}
+		w.WriteHeader(200)
+	default:
+		// Unsubscribing is never asked for, subscriptions run out instead.
+		respondWithError(w, 404, fmt.Sprintf("Unexpected mode %q", query.Get("hub.mode")))
+	}
+}
+
+// webSubAwaitingVerification reports whether a hub may still verify a request
+// made for subscription: a new one, or the renewal of an active one, asked
+// for less than websub.VerifyTimeout ago.
+func webSubAwaitingVerification(subscription database.WebsubSubscription) bool {
+	if time.Since(subscription.RequestedAt) > websub.VerifyTimeout {
+		return false
+	}
+	switch subscription.State {
+	case "pending":
+		return true
+	case "active":
+		return subscription.RequestedAt.After(subscription.VerifiedAt.Time)
+	}
+	return false
+}
+
+// HandlerWebSubPush stores the content a hub pushes like a polled feed.
+// Content without a valid X-Hub-Signature is acknowledged, as hubs expect,
+// but dropped.
+func (apiCfg *ApiConfig) HandlerWebSubPush(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := apiCfg.webSubSubscription(w, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPushSize+1))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't read content: %v", err))
+		return
+	}
+	if len(body) > maxPushSize {
+		respondWithError(w, 413, "Content too large")
+		return
+	}
+	if !websub.VerifySignature(subscription.Secret, r.Header.Get("X-Hub-Signature"), body) {
+		log.Printf("Dropped content pushed for %s with an invalid signature", subscription.Topic)
+		w.WriteHeader(202)
+		return
+	}
+	feed, err := apiCfg.DB.GetFeed(r.Context(), subscription.FeedID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldn't get feed: %v", err))
+		return
+	}
+	result, err := apiCfg.ingester().IngestDocument(r.Context(), ingest.FeedFromDB(feed), ingest.Document{
+		URL:         subscription.Topic,
+		ContentType: r.Header.Get("Content-Type"),
+		Body:        body,
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't ingest content: %v", err))
+		return
+	}
+	err = apiCfg.DB.RecordWebSubPush(r.Context(), subscription.FeedID)
+	if err != nil {
+		log.Println("Couldn't record push: ", err)
+	}
+	log.Printf("Feed %s pushed, %d posts found, %d new", feed.Name, len(result.Items), result.Created)
+	w.WriteHeader(202)
+}
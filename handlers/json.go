@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"log"
 	"net/http"
 )
 
 type WrappedSlice[T any] struct {
-	Results []T `json:"results"`
-	Size    int `json:"size"`
+	Results    []T    `json:"results"`
+	Size       int    `json:"size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
 }
 
 func respondWithJson(w http.ResponseWriter, code int, payload interface{}) {
@@ -32,3 +36,16 @@ func respondWithError(w http.ResponseWriter, code int, msg string) {
 	}
 	respondWithJson(w, code, ErrorResponse{Error: msg})
 }
+
+func respondWithXML(w http.ResponseWriter, code int, contentType string, payload interface{}) {
+	data, err := xml.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		log.Printf("Failed to marshal: %v", payload)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Add("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
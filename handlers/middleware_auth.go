@@ -1,28 +1,199 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/leguzman/rss-project/internal/auth"
 	"github.com/leguzman/rss-project/internal/database"
 )
 
 type AuthedHandler func(http.ResponseWriter, *http.Request, database.User)
 
-func (apiCfg *ApiConfig) MiddlewareAuth(handler AuthedHandler) http.HandlerFunc {
+type contextKey string
+
+var errUserDisabled = errors.New("account disabled")
+
+const (
+	apiKeyContextKey  contextKey = "apiKey"
+	sessionContextKey contextKey = "session"
+	scopesContextKey  contextKey = "scopes"
+)
+
+// ApiKeyFromContext returns the API key the request was authenticated with.
+func ApiKeyFromContext(ctx context.Context) (database.ApiKey, bool) {
+	apiKey, ok := ctx.Value(apiKeyContextKey).(database.ApiKey)
+	return apiKey, ok
+}
+
+// SessionFromContext returns the session the request was authenticated with.
+func SessionFromContext(ctx context.Context) (database.Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(database.Session)
+	return session, ok
+}
+
+// ScopesFromContext returns the scopes held by the caller of an AuthedHandler.
+func ScopesFromContext(ctx context.Context) []auth.Scope {
+	scopes, _ := ctx.Value(scopesContextKey).([]auth.Scope)
+	return scopes
+}
+
+// MiddlewareAuth authenticates the request with its API key, rejecting keys
+// that don't hold the scope the route requires. Browsers that logged in send
+// their session cookie instead, sessions hold every scope. Services may
+// present a JWT bearer token, whose claims name the user and scopes.
+func (apiCfg *ApiConfig) MiddlewareAuth(scope auth.Scope, handler AuthedHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		switch {
+		case header == "":
+			if token, err := auth.GetSessionToken(r); err == nil {
+				apiCfg.authSession(w, r, token, scope, handler)
+				return
+			}
+		case strings.HasPrefix(header, "Bearer "):
+			apiCfg.authJWT(w, r, scope, handler)
+			return
+		}
 		apiKey, err := auth.GetApiKey(r.Header)
 		if err != nil {
 			respondWithError(w, 403, fmt.Sprintf("Auth error: %v", err))
 			return
 		}
-		user, err := apiCfg.DB.GetUserByApikey(r.Context(), apiKey)
+		user, key, err := apiCfg.userFromApiKey(r.Context(), apiKey)
 		if err != nil {
 			respondWithError(w, 400, fmt.Sprintf("Couldn't get user: %v", err))
 			return
 		}
+		r, err = withApiKey(r, key, scope)
+		if err != nil {
+			respondWithError(w, 403, fmt.Sprintf("Auth error: %v", err))
+			return
+		}
+		apiCfg.serveAuthed(w, r, "key:"+key.ID.String(), user, handler)
+	}
+
+}
+
+// MiddlewareAdmin only lets operators through: users flagged as admin,
+// authenticated with a credential holding the admin scope.
+func (apiCfg *ApiConfig) MiddlewareAdmin(handler AuthedHandler) http.HandlerFunc {
+	return apiCfg.MiddlewareAuth(auth.ScopeAdmin, func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if !user.IsAdmin {
+			respondWithError(w, 403, "Auth error: admins only")
+			return
+		}
 		handler(w, r, user)
+	})
+}
+
+// MiddlewareGoogleLogin authenticates Google Reader API clients, which send
+// the token obtained from ClientLogin instead of the ApiKey header.
+func (apiCfg *ApiConfig) MiddlewareGoogleLogin(scope auth.Scope, handler AuthedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetGoogleLoginToken(r.Header)
+		if err != nil {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		user, key, err := apiCfg.userFromGReaderToken(r.Context(), token)
+		if err != nil {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		r, err = withApiKey(r, key, scope)
+		if err != nil {
+			http.Error(w, "Forbidden", 403)
+			return
+		}
+		apiCfg.serveAuthed(w, r, "key:"+key.ID.String(), user, handler)
+	}
+}
+
+func (apiCfg *ApiConfig) authSession(w http.ResponseWriter, r *http.Request, token string, scope auth.Scope, handler AuthedHandler) {
+	session, err := apiCfg.DB.UseSession(r.Context(), auth.HashApiKey(token))
+	if err != nil {
+		respondWithError(w, 401, "Auth error: session expired or logged out")
+		return
+	}
+	err = auth.CheckCSRF(r, session.CsrfToken)
+	if err != nil {
+		respondWithError(w, 403, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+	user, err := apiCfg.activeUser(r.Context(), session.UserID)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get user: %v", err))
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), sessionContextKey, session))
+	r, err = withScopes(r, auth.AllScopes, scope)
+	if err != nil {
+		respondWithError(w, 403, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+	apiCfg.serveAuthed(w, r, "user:"+user.ID.String(), user, handler)
+}
+
+func (apiCfg *ApiConfig) authJWT(w http.ResponseWriter, r *http.Request, scope auth.Scope, handler AuthedHandler) {
+	if apiCfg.JWT == nil {
+		respondWithError(w, 401, "Auth error: bearer tokens aren't accepted")
+		return
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+	identity, err := apiCfg.JWT.Verify(token)
+	if err != nil {
+		respondWithError(w, 401, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+	user, err := apiCfg.activeUser(r.Context(), identity.UserID)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldn't get user: %v", err))
+		return
+	}
+	r, err = withScopes(r, identity.Scopes, scope)
+	if err != nil {
+		respondWithError(w, 403, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+	apiCfg.serveAuthed(w, r, "user:"+user.ID.String(), user, handler)
+}
+
+// activeUser gets the user with id, unless an admin disabled them.
+func (apiCfg *ApiConfig) activeUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := apiCfg.DB.GetUserByID(ctx, id)
+	if err == nil && user.DisabledAt.Valid {
+		return database.User{}, errUserDisabled
 	}
+	return user, err
+}
 
+// withApiKey checks key holds the required scope and stores it, along with
+// its scopes, in the context of r.
+func withApiKey(r *http.Request, key database.ApiKey, required auth.Scope) (*http.Request, error) {
+	scopes, err := auth.ParseScopes(key.Scopes)
+	if err != nil {
+		return r, err
+	}
+	if !auth.HasScope(scopes, required) {
+		return r, fmt.Errorf("API key lacks the %s scope", required)
+	}
+	return withScopes(r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)), scopes, required)
+}
+
+// withScopes checks the scopes held grant the required one and stores them
+// in the context of r.
+func withScopes(r *http.Request, held []auth.Scope, required auth.Scope) (*http.Request, error) {
+	if !auth.HasScope(held, required) {
+		return r, fmt.Errorf("lacking the %s scope", required)
+	}
+	return r.WithContext(context.WithValue(r.Context(), scopesContextKey, held)), nil
 }
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/leguzman/rss-project/internal/database"
+)
+
+const (
+	rateLimitGroupContextKey contextKey = "rateLimitGroup"
+	authAttemptContextKey    contextKey = "authAttempt"
+)
+
+// authAttempt is set by serveAuthed once the caller of a rate limited route
+// authenticated.
+type authAttempt struct {
+	authenticated bool
+}
+
+// MiddlewareRateLimit puts the routes it wraps in a rate limit group. The
+// limit is enforced by MiddlewareAuth once it knows who is calling, per API
+// key, or per user for sessions and bearer tokens. Requests that fail to
+// authenticate count against the auth group of their IP instead, which
+// turns away an IP that ran out before its credentials are even checked.
+func (apiCfg *ApiConfig) MiddlewareRateLimit(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := "ip:" + apiCfg.clientIP(r)
+			if !apiCfg.checkRateLimit(w, r, "auth", ip) {
+				return
+			}
+			attempt := &authAttempt{}
+			ctx := context.WithValue(r.Context(), rateLimitGroupContextKey, group)
+			ctx = context.WithValue(ctx, authAttemptContextKey, attempt)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			if !attempt.authenticated && apiCfg.RateLimiter != nil {
+				_, _, err := apiCfg.RateLimiter.Allow(r.Context(), "auth", ip)
+				if err != nil {
+					log.Printf("Couldn't count failed authentication of %s: %v", ip, err)
+				}
+			}
+		})
+	}
+}
+
+// MiddlewareRateLimitByIP enforces the limit of group per client IP, for
+// routes anyone can call.
+func (apiCfg *ApiConfig) MiddlewareRateLimitByIP(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiCfg.rateLimit(w, r, group, "ip:"+apiCfg.clientIP(r)) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// serveAuthed calls handler unless caller went over the rate limit of the
+// group of the route.
+func (apiCfg *ApiConfig) serveAuthed(w http.ResponseWriter, r *http.Request, caller string, user database.User, handler AuthedHandler) {
+	if attempt, ok := r.Context().Value(authAttemptContextKey).(*authAttempt); ok {
+		attempt.authenticated = true
+	}
+	if group, ok := r.Context().Value(rateLimitGroupContextKey).(string); ok {
+		if !apiCfg.rateLimit(w, r, group, caller) {
+			return
+		}
+	}
+	handler(w, r, user)
+}
+
+// checkRateLimit answers 429 and reports false when the bucket of key is
+// empty, without taking a token from it. Requests go through when the store
+// fails.
+func (apiCfg *ApiConfig) checkRateLimit(w http.ResponseWriter, r *http.Request, group, key string) bool {
+	if apiCfg.RateLimiter == nil {
+		return true
+	}
+	result, ok, err := apiCfg.RateLimiter.Check(r.Context(), group, key)
+	if err != nil {
+		log.Printf("Couldn't check rate limit of %s: %v", key, err)
+		return true
+	}
+	if !ok || result.Allowed {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(result.RetryAfter.Seconds())))
+	respondWithError(w, 429, "Too many failed authentications")
+	return false
+}
+
+// rateLimit takes a token from the bucket of key and sets the RateLimit
+// headers. It reports whether the request may go on, having answered 429
+// otherwise. Requests go through when the store fails.
+func (apiCfg *ApiConfig) rateLimit(w http.ResponseWriter, r *http.Request, group, key string) bool {
+	if apiCfg.RateLimiter == nil {
+		return true
+	}
+	result, ok, err := apiCfg.RateLimiter.Allow(r.Context(), group, key)
+	if err != nil {
+		log.Printf("Couldn't check rate limit of %s: %v", key, err)
+		return true
+	}
+	if !ok {
+		return true
+	}
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(int(result.Reset.Seconds())))
+	if !result.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(result.RetryAfter.Seconds())))
+		respondWithError(w, 429, "Too many requests")
+		return false
+	}
+	return true
+}
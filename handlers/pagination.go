@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/leguzman/rss-project/internal/postfilter"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 500
+)
+
+// pageSize reads the limit query parameter, capped to maxPageSize.
+func pageSize(r *http.Request) int32 {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	return int32(min(limit, maxPageSize))
+}
+
+// wantsTotal reports whether the client asked for the total count of results
+// with ?count=true, which costs an extra query.
+func wantsTotal(r *http.Request) bool {
+	count, _ := strconv.ParseBool(r.URL.Query().Get("count"))
+	return count
+}
+
+func encodeCursor(cursor postfilter.Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses the cursor query parameter, returning nil for the
+// first page.
+func decodeCursor(r *http.Request) (*postfilter.Cursor, error) {
+	value := r.URL.Query().Get("cursor")
+	if value == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	cursor := postfilter.Cursor{}
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	return &cursor, nil
+}
+
+// pageCursors builds the cursors around a page of rows fetched with one extra
+// row (to know whether more follow in the direction of the request), trimming
+// that row. keys returns the sort key values of a row.
+func pageCursors[T any](rows []T, limit int32, cursor *postfilter.Cursor, keys func(T) postfilter.Cursor) ([]T, string, string) {
+	backward := cursor != nil && cursor.Backward
+	hasMore := len(rows) > int(limit)
+	if hasMore {
+		if backward {
+			rows = rows[1:]
+		} else {
+			rows = rows[:limit]
+		}
+	}
+	if len(rows) == 0 {
+		return rows, "", ""
+	}
+	next, prev := "", ""
+	if backward || hasMore {
+		next = encodeCursor(keys(rows[len(rows)-1]))
+	}
+	if (backward && hasMore) || (!backward && cursor != nil) {
+		first := keys(rows[0])
+		first.Backward = true
+		prev = encodeCursor(first)
+	}
+	return rows, next, prev
+}
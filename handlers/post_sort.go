@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/leguzman/rss-project/internal/postfilter"
+)
+
+const defaultPostSort = "-published_at"
+
+// parsePostSort parses a comma separated list of columns, each optionally
+// prefixed by "-" for a descending or "+" for an ascending order, e.g.
+// "-published_at,title". An unescaped "+" arrives as a space, so leading
+// spaces are ignored too.
+func parsePostSort(value string) ([]postfilter.Sort, error) {
+	if strings.TrimSpace(value) == "" {
+		value = defaultPostSort
+	}
+	sort := []postfilter.Sort{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		column := strings.TrimLeft(part, "+-")
+		if len(part)-len(column) > 1 {
+			return nil, fmt.Errorf("malformed sort %q", part)
+		}
+		if !postfilter.IsSortColumn(column) {
+			return nil, fmt.Errorf("unknown sort column %q", column)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("duplicate sort column %q", column)
+		}
+		seen[column] = true
+		sort = append(sort, postfilter.Sort{Column: column, Desc: strings.HasPrefix(part, "-")})
+	}
+	return sort, nil
+}
+
+// formatPostSort is the canonical form of sort, used to tie cursors to the
+// sort they were built with.
+func formatPostSort(sort []postfilter.Sort) string {
+	parts := make([]string, 0, len(sort))
+	for _, column := range sort {
+		if column.Desc {
+			parts = append(parts, "-"+column.Column)
+		} else {
+			parts = append(parts, column.Column)
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const apiKeyPrefixLength = 8
+
+// NewApiKey is a freshly minted API key. Only Hash and Prefix are stored, Key
+// is shown to the user once and can't be recovered afterwards.
+type NewApiKey struct {
+	Key    string
+	Prefix string
+	Hash   string
+}
+
+func GenerateApiKey() (NewApiKey, error) {
+	key, err := randomToken()
+	if err != nil {
+		return NewApiKey{}, err
+	}
+	return NewApiKey{
+		Key:    key,
+		Prefix: key[:apiKeyPrefixLength],
+		Hash:   HashApiKey(key),
+	}, nil
+}
+
+// GenerateFeedToken returns the secret in the timeline URLs of a user.
+func GenerateFeedToken() (string, error) {
+	return randomToken()
+}
+
+// GenerateGReaderToken returns the token Google Reader clients are handed at
+// login in place of their API key. Only its HashApiKey is stored.
+func GenerateGReaderToken() (string, error) {
+	return randomToken()
+}
+
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// FeverKey is the key Fever clients derive from the user name and API key,
+// md5("<name>:<key>").
+func FeverKey(userName, key string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(userName+":"+key)))
+}
@@ -20,3 +20,17 @@ func GetApiKey(headers http.Header) (string, error) {
 	}
 	return vals[1], nil
 }
+
+// GetGoogleLoginToken extracts the token sent by Google Reader clients as
+// "Authorization: GoogleLogin auth=<token>".
+func GetGoogleLoginToken(headers http.Header) (string, error) {
+	val := headers.Get("Authorization")
+	if val == "" {
+		return "", errors.New("no Authentication info found")
+	}
+	token, found := strings.CutPrefix(val, "GoogleLogin auth=")
+	if !found || token == "" {
+		return "", errors.New("malformed auth header")
+	}
+	return token, nil
+}
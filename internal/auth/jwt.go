@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// DefaultJWTMaxLifetime is how long bearer tokens may be valid for, from
+// when they were issued to when they expire, unless configured otherwise.
+const DefaultJWTMaxLifetime = 24 * time.Hour
+
+// JWTConfig configures the keys bearer tokens may be signed with. Any
+// combination of an HMAC secret, a PEM public key and a JWKS file works.
+// MaxLifetime defaults to DefaultJWTMaxLifetime.
+type JWTConfig struct {
+	HMACSecret    string
+	PublicKeyFile string
+	JWKSFile      string
+	Issuer        string
+	Audience      string
+	MaxLifetime   time.Duration
+}
+
+// JWTConfigFromEnv reads JWT_HMAC_SECRET, JWT_PUBLIC_KEY_FILE, JWT_JWKS_FILE,
+// JWT_ISSUER, JWT_AUDIENCE and JWT_MAX_LIFETIME_SECONDS, reporting whether
+// bearer tokens are accepted at all.
+func JWTConfigFromEnv() (JWTConfig, bool, error) {
+	config := JWTConfig{
+		HMACSecret:    os.Getenv("JWT_HMAC_SECRET"),
+		PublicKeyFile: os.Getenv("JWT_PUBLIC_KEY_FILE"),
+		JWKSFile:      os.Getenv("JWT_JWKS_FILE"),
+		Issuer:        os.Getenv("JWT_ISSUER"),
+		Audience:      os.Getenv("JWT_AUDIENCE"),
+		MaxLifetime:   DefaultJWTMaxLifetime,
+	}
+	if config.HMACSecret == "" && config.PublicKeyFile == "" && config.JWKSFile == "" {
+		return JWTConfig{}, false, nil
+	}
+	if value := os.Getenv("JWT_MAX_LIFETIME_SECONDS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return JWTConfig{}, false, errors.New("JWT_MAX_LIFETIME_SECONDS must be a positive number of seconds")
+		}
+		config.MaxLifetime = time.Duration(n) * time.Second
+	}
+	return config, true, nil
+}
+
+type JWTVerifier struct {
+	hmacSecret []byte
+	// publicKey is the key from the PEM file, used for tokens without a kid
+	// or whose kid isn't in keys.
+	publicKey interface{}
+	keys      map[string]interface{}
+	methods   []string
+	options   []jwt.ParserOption
+	// maxLifetime caps exp - iat, so a leaked token can't be used for long
+	// whatever expiry its issuer gave it.
+	maxLifetime time.Duration
+}
+
+// JWTIdentity is the user a bearer token was issued for and what it allows.
+type JWTIdentity struct {
+	UserID uuid.UUID
+	Scopes []Scope
+}
+
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	// Scope is the space separated scope claim of RFC 8693, Scopes the array
+	// some issuers send instead.
+	Scope  string   `json:"scope"`
+	Scopes []string `json:"scopes"`
+}
+
+func NewJWTVerifier(config JWTConfig) (*JWTVerifier, error) {
+	verifier := &JWTVerifier{keys: map[string]interface{}{}, maxLifetime: config.MaxLifetime}
+	if verifier.maxLifetime <= 0 {
+		verifier.maxLifetime = DefaultJWTMaxLifetime
+	}
+	if config.HMACSecret != "" {
+		verifier.hmacSecret = []byte(config.HMACSecret)
+		verifier.methods = append(verifier.methods, "HS256", "HS384", "HS512")
+	}
+	if config.PublicKeyFile != "" {
+		data, err := os.ReadFile(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier.publicKey, err = parsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s: %w", config.PublicKeyFile, err)
+		}
+	}
+	if config.JWKSFile != "" {
+		data, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier.keys, err = ParseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s: %w", config.JWKSFile, err)
+		}
+	}
+	if verifier.publicKey != nil || len(verifier.keys) > 0 {
+		verifier.methods = append(verifier.methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512")
+	}
+	if len(verifier.methods) == 0 {
+		return nil, errors.New("no key to verify bearer tokens with")
+	}
+	verifier.options = []jwt.ParserOption{
+		jwt.WithValidMethods(verifier.methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if config.Issuer != "" {
+		verifier.options = append(verifier.options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		verifier.options = append(verifier.options, jwt.WithAudience(config.Audience))
+	}
+	return verifier, nil
+}
+
+// Verify checks the signature and claims of token, whose subject must be the
+// id of a user. It must say when it was issued, and expire within the
+// maximum lifetime of that. Scopes this API doesn't know are ignored.
+func (v *JWTVerifier) Verify(token string) (JWTIdentity, error) {
+	claims := jwtClaims{}
+	_, err := jwt.ParseWithClaims(token, &claims, v.key, v.options...)
+	if err != nil {
+		return JWTIdentity{}, err
+	}
+	if claims.IssuedAt == nil {
+		return JWTIdentity{}, errors.New("token has no iat claim")
+	}
+	if claims.ExpiresAt.Sub(claims.IssuedAt.Time) > v.maxLifetime {
+		return JWTIdentity{}, fmt.Errorf("token is valid for longer than %v", v.maxLifetime)
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return JWTIdentity{}, errors.New("token subject isn't a user id")
+	}
+	identity := JWTIdentity{UserID: userID, Scopes: []Scope{}}
+	for _, value := range append(strings.Fields(claims.Scope), claims.Scopes...) {
+		if _, ok := scopeLevels[Scope(value)]; ok {
+			identity.Scopes = append(identity.Scopes, Scope(value))
+		}
+	}
+	return identity, nil
+}
+
+func (v *JWTVerifier) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.hmacSecret, nil
+	}
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok := v.keys[kid]; ok {
+			return key, nil
+		}
+	}
+	if v.publicKey != nil {
+		return v.publicKey, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// GetBearerToken extracts the token sent as "Authorization: Bearer <token>".
+func GetBearerToken(headers http.Header) (string, error) {
+	val := headers.Get("Authorization")
+	if val == "" {
+		return "", errors.New("no Authentication info found")
+	}
+	token, found := strings.CutPrefix(val, "Bearer ")
+	if !found || token == "" {
+		return "", errors.New("malformed auth header")
+	}
+	return token, nil
+}
+
+func parsePublicKeyPEM(data []byte) (interface{}, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("not an RSA or ECDSA public key")
+}
+
+// ParseJWKS reads the RSA and EC keys of a JSON Web Key Set (RFC 7517),
+// indexed by kid. Other key types are skipped.
+func ParseJWKS(data []byte) (map[string]interface{}, error) {
+	set := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}{}
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				return nil, fmt.Errorf("key %q: unsupported curve %q", jwk.Kid, jwk.Crv)
+			}
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+			}
+			y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTVerifyHMAC(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTConfig{HMACSecret: "secret", Issuer: "billing"})
+	assert.NoError(t, err)
+	userID := uuid.New()
+	sign := func(claims jwt.MapClaims, secret string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "billing",
+			"sub":   userID.String(),
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"scope": "openid read",
+		}
+	}
+
+	identity, err := verifier.Verify(sign(claims(), "secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, JWTIdentity{UserID: userID, Scopes: []Scope{ScopeRead}}, identity)
+
+	_, err = verifier.Verify(sign(claims(), "guessed"))
+	assert.Error(t, err, "wrong signature")
+
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	_, err = verifier.Verify(sign(expired, "secret"))
+	assert.Error(t, err, "expired")
+
+	lasting := claims()
+	delete(lasting, "exp")
+	_, err = verifier.Verify(sign(lasting, "secret"))
+	assert.Error(t, err, "no expiry")
+
+	unissued := claims()
+	delete(unissued, "iat")
+	_, err = verifier.Verify(sign(unissued, "secret"))
+	assert.Error(t, err, "no issue time")
+
+	longLived := claims()
+	longLived["exp"] = time.Now().Add(DefaultJWTMaxLifetime + time.Minute).Unix()
+	_, err = verifier.Verify(sign(longLived, "secret"))
+	assert.Error(t, err, "valid for too long")
+
+	// A token issued a day ago and still valid has lived too long as well.
+	longAgo := claims()
+	longAgo["iat"] = time.Now().Add(-DefaultJWTMaxLifetime).Unix()
+	_, err = verifier.Verify(sign(longAgo, "secret"))
+	assert.Error(t, err, "issued long ago")
+
+	foreign := claims()
+	foreign["iss"] = "elsewhere"
+	_, err = verifier.Verify(sign(foreign, "secret"))
+	assert.Error(t, err, "wrong issuer")
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	_, err = verifier.Verify(unsigned)
+	assert.Error(t, err, "alg none")
+}
+
+func TestJWTVerifyJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "2024",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(jwksFile, jwks, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewJWTVerifier(JWTConfig{JWKSFile: jwksFile, Audience: "rss"})
+	assert.NoError(t, err)
+
+	userID := uuid.New()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub":    userID.String(),
+		"aud":    "rss",
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(time.Minute).Unix(),
+		"scopes": []string{"feeds"},
+	})
+	token.Header["kid"] = "2024"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := verifier.Verify(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, JWTIdentity{UserID: userID, Scopes: []Scope{ScopeFeeds}}, identity)
+
+	// A symmetric token must not be accepted when only public keys are set.
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": userID.String(),
+		"aud": "rss",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("anything"))
+	_, err = verifier.Verify(hmacToken)
+	assert.Error(t, err)
+}
+
+func TestJWTMaxLifetime(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTConfig{HMACSecret: "secret", MaxLifetime: time.Hour})
+	assert.NoError(t, err)
+	sign := func(lifetime time.Duration) string {
+		now := time.Now()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": uuid.NewString(),
+			"iat": now.Unix(),
+			"exp": now.Add(lifetime).Unix(),
+		}).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	_, err = verifier.Verify(sign(time.Hour))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(sign(365 * 24 * time.Hour))
+	assert.Error(t, err)
+
+	t.Setenv("JWT_HMAC_SECRET", "secret")
+	t.Setenv("JWT_MAX_LIFETIME_SECONDS", "600")
+	config, ok, err := JWTConfigFromEnv()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Minute, config.MaxLifetime)
+	t.Setenv("JWT_MAX_LIFETIME_SECONDS", "forever")
+	_, _, err = JWTConfigFromEnv()
+	assert.Error(t, err)
+}
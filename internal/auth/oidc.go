@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig configures single sign-on with an OpenID Connect identity
+// provider, read from the environment like DB_URL and PORT.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// PostLoginURL is where browsers land once logged in. When empty, the
+	// callback answers with the session as JSON instead.
+	PostLoginURL string
+}
+
+// OIDCConfigFromEnv reads OIDC_ISSUER_URL, OIDC_CLIENT_ID,
+// OIDC_CLIENT_SECRET, OIDC_REDIRECT_URL and OIDC_POST_LOGIN_URL, reporting
+// whether single sign-on is enabled at all.
+func OIDCConfigFromEnv() (OIDCConfig, bool) {
+	config := OIDCConfig{
+		IssuerURL:    os.Getenv("OIDC_ISSUER_URL"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		PostLoginURL: os.Getenv("OIDC_POST_LOGIN_URL"),
+	}
+	return config, config.IssuerURL != ""
+}
+
+type OIDCProvider struct {
+	PostLoginURL string
+	oauth2       oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+}
+
+// OIDCLogin is what a login started with AuthCodeURL has to remember until
+// the identity provider redirects back to the callback.
+type OIDCLogin struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	// LinkUserID is the user linking the identity to their account, empty
+	// when logging in.
+	LinkUserID string
+}
+
+// OIDCIdentity is who the identity provider vouched for.
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// NewOIDCProvider discovers the endpoints and signing keys of the identity
+// provider at config.IssuerURL.
+func NewOIDCProvider(ctx context.Context, config OIDCConfig) (*OIDCProvider, error) {
+	if config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required")
+	}
+	provider, err := oidc.NewProvider(ctx, config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't discover %s: %w", config.IssuerURL, err)
+	}
+	return &OIDCProvider{
+		PostLoginURL: config.PostLoginURL,
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+	}, nil
+}
+
+// AuthCodeURL starts a login, returning the URL of the identity provider to
+// send the browser to and what to keep until it comes back.
+func (p *OIDCProvider) AuthCodeURL() (string, OIDCLogin, error) {
+	state, err := randomToken()
+	if err != nil {
+		return "", OIDCLogin{}, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", OIDCLogin{}, err
+	}
+	login := OIDCLogin{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+	}
+	url := p.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(login.CodeVerifier))
+	return url, login, nil
+}
+
+// Exchange redeems the authorization code the identity provider redirected
+// back with, validating the ID token it returns against login.
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, login OIDCLogin) (OIDCIdentity, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(login.CodeVerifier))
+	if err != nil {
+		return OIDCIdentity{}, fmt.Errorf("couldn't redeem code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return OIDCIdentity{}, errors.New("no id_token in token response")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return OIDCIdentity{}, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if idToken.Nonce != login.Nonce {
+		return OIDCIdentity{}, errors.New("id_token nonce doesn't match")
+	}
+	claims := struct {
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+	}{}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return OIDCIdentity{}, fmt.Errorf("couldn't parse id_token claims: %w", err)
+	}
+	identity := OIDCIdentity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.PreferredUsername,
+	}
+	if identity.Name == "" {
+		identity.Name = claims.Name
+	}
+	if identity.Name == "" {
+		identity.Name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	if identity.Name == "" {
+		identity.Name = idToken.Subject
+	}
+	return identity, nil
+}
+
+// Encode serializes login to be kept in a cookie until the callback.
+func (login OIDCLogin) Encode() string {
+	value := login.State + "." + login.Nonce + "." + login.CodeVerifier
+	if login.LinkUserID != "" {
+		value += "." + login.LinkUserID
+	}
+	return value
+}
+
+func DecodeOIDCLogin(value string) (OIDCLogin, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 && len(parts) != 4 {
+		return OIDCLogin{}, errors.New("malformed OIDC login")
+	}
+	login := OIDCLogin{State: parts[0], Nonce: parts[1], CodeVerifier: parts[2]}
+	if len(parts) == 4 {
+		login.LinkUserID = parts[3]
+	}
+	return login, nil
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockIdentityProvider is a minimal OpenID Connect provider issuing an ID
+// token for a single authorization code.
+type mockIdentityProvider struct {
+	*httptest.Server
+	key           *rsa.PrivateKey
+	code          string
+	codeChallenge string
+	nonce         string
+	claims        map[string]interface{}
+}
+
+func newMockIdentityProvider(t *testing.T) *mockIdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdentityProvider{key: key, code: "the-code"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != idp.code || base64.RawURLEncoding.EncodeToString(verifier[:]) != idp.codeChallenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		claims := map[string]interface{}{
+			"iss":   idp.URL,
+			"sub":   "user-1",
+			"aud":   "client",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": idp.nonce,
+		}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idp.sign(t, claims),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *mockIdentityProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize plays the part of the browser visiting the authorization URL.
+func (idp *mockIdentityProvider) authorize(t *testing.T, authURL string) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	idp.codeChallenge = query.Get("code_challenge")
+	idp.nonce = query.Get("nonce")
+}
+
+func newTestOIDCProvider(t *testing.T, idp *mockIdentityProvider) *OIDCProvider {
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		IssuerURL:   idp.URL,
+		ClientID:    "client",
+		RedirectURL: "http://localhost/v1/auth/oidc/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestOIDCExchange(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	idp.claims = map[string]interface{}{
+		"email":              "ada@example.com",
+		"email_verified":     true,
+		"preferred_username": "ada",
+	}
+	provider := newTestOIDCProvider(t, idp)
+
+	authURL, login, err := provider.AuthCodeURL()
+	assert.NoError(t, err)
+	idp.authorize(t, authURL)
+	decoded, err := DecodeOIDCLogin(login.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, login, decoded)
+	linking := login
+	linking.LinkUserID = "0b7f3c1e-9a52-4a3f-8d2e-4f6a1c9b0d11"
+	linkDecoded, err := DecodeOIDCLogin(linking.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, linking, linkDecoded)
+
+	identity, err := provider.Exchange(context.Background(), idp.code, decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, OIDCIdentity{
+		Issuer:        idp.URL,
+		Subject:       "user-1",
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		Name:          "ada",
+	}, identity)
+}
+
+func TestOIDCExchangeRejectsWrongVerifier(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	provider := newTestOIDCProvider(t, idp)
+
+	authURL, login, err := provider.AuthCodeURL()
+	assert.NoError(t, err)
+	idp.authorize(t, authURL)
+	login.CodeVerifier = "stolen-code-without-its-verifier"
+
+	_, err = provider.Exchange(context.Background(), idp.code, login)
+	assert.Error(t, err)
+}
+
+func TestOIDCExchangeRejectsWrongNonce(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	provider := newTestOIDCProvider(t, idp)
+
+	authURL, login, err := provider.AuthCodeURL()
+	assert.NoError(t, err)
+	idp.authorize(t, authURL)
+	idp.nonce = "replayed"
+
+	_, err = provider.Exchange(context.Background(), idp.code, login)
+	assert.ErrorContains(t, err, "nonce")
+}
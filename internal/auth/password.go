@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores anything past 72 bytes, reject those instead of
+	// silently truncating them.
+	maxPasswordLength = 72
+)
+
+// dummyPasswordHash is compared against when the user doesn't exist, so
+// logging in takes as long whether or not the name is taken.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", errors.New("password must be at least 8 characters long")
+	}
+	if len(password) > maxPasswordLength {
+		return "", errors.New("password must be at most 72 bytes long")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword reports whether password matches hash. An empty hash never
+// matches, but takes as long as one that doesn't.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
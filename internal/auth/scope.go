@@ -0,0 +1,53 @@
+package auth
+
+import "fmt"
+
+// Scope limits what a credential may do. Each scope implies the ones below
+// it: admin can do everything feeds can, which can do everything read can.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeFeeds Scope = "feeds"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeLevels = map[Scope]int{
+	ScopeRead:  1,
+	ScopeFeeds: 2,
+	ScopeAdmin: 3,
+}
+
+// AllScopes is held by credentials that aren't restricted, like the key a
+// user gets when signing up.
+var AllScopes = []Scope{ScopeAdmin}
+
+func ParseScopes(values []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(values))
+	for _, value := range values {
+		scope := Scope(value)
+		if _, ok := scopeLevels[scope]; !ok {
+			return nil, fmt.Errorf("unknown scope %q", value)
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// HasScope reports whether the scopes held grant the required one.
+func HasScope(held []Scope, required Scope) bool {
+	for _, scope := range held {
+		if scopeLevels[scope] >= scopeLevels[required] {
+			return true
+		}
+	}
+	return false
+}
+
+func ScopeStrings(scopes []Scope) []string {
+	values := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		values = append(values, string(scope))
+	}
+	return values
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+const (
+	// SessionCookieName holds the session token. It is HttpOnly, scripts
+	// never see it.
+	SessionCookieName = "session"
+	// CSRFCookieName holds the CSRF token, readable by scripts of the front-end
+	// so they can echo it in CSRFHeader.
+	CSRFCookieName = "csrf_token"
+	CSRFHeader     = "X-CSRF-Token"
+)
+
+// NewSession is a freshly started session. Only Hash is stored, Token lives
+// in the session cookie.
+type NewSession struct {
+	Token     string
+	Hash      string
+	CSRFToken string
+}
+
+func GenerateSession() (NewSession, error) {
+	token, err := randomToken()
+	if err != nil {
+		return NewSession{}, err
+	}
+	csrfToken, err := randomToken()
+	if err != nil {
+		return NewSession{}, err
+	}
+	return NewSession{
+		Token:     token,
+		Hash:      HashApiKey(token),
+		CSRFToken: csrfToken,
+	}, nil
+}
+
+// GetSessionToken extracts the session token from the cookies of r.
+func GetSessionToken(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return "", errors.New("no session cookie found")
+	}
+	return cookie.Value, nil
+}
+
+// CheckCSRF verifies requests that may change state echo the CSRF token of
+// their session in CSRFHeader. Safe methods don't need to.
+func CheckCSRF(r *http.Request, csrfToken string) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	header := r.Header.Get(CSRFHeader)
+	if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(csrfToken)) != 1 {
+		return errors.New("missing or invalid CSRF token")
+	}
+	return nil
+}
+
+func randomToken() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
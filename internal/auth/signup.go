@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/bits"
+	"os"
+	"strconv"
+)
+
+// SignupMode is who may create an account with POST /v1/users.
+type SignupMode string
+
+const (
+	SignupOpen   SignupMode = "open"
+	SignupInvite SignupMode = "invite"
+	// SignupAdmin only lets admins create accounts, through the admin API.
+	SignupAdmin SignupMode = "admin"
+)
+
+// maxProofOfWorkBits keeps the work within what a browser does in seconds.
+const maxProofOfWorkBits = 32
+
+type SignupConfig struct {
+	Mode SignupMode
+	// ProofOfWorkBits is how many leading zero bits the hash of a signup
+	// challenge and its nonce needs, 0 to not require proof of work.
+	ProofOfWorkBits int
+}
+
+// SignupConfigFromEnv reads SIGNUP_MODE, open by default, and
+// SIGNUP_POW_BITS.
+func SignupConfigFromEnv() (SignupConfig, error) {
+	config := SignupConfig{Mode: SignupMode(os.Getenv("SIGNUP_MODE"))}
+	switch config.Mode {
+	case "":
+		config.Mode = SignupOpen
+	case SignupOpen, SignupInvite, SignupAdmin:
+	default:
+		return SignupConfig{}, fmt.Errorf("unknown SIGNUP_MODE %q", config.Mode)
+	}
+	if value := os.Getenv("SIGNUP_POW_BITS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxProofOfWorkBits {
+			return SignupConfig{}, fmt.Errorf("SIGNUP_POW_BITS must be between 0 and %d", maxProofOfWorkBits)
+		}
+		config.ProofOfWorkBits = n
+	}
+	return config, nil
+}
+
+// NewInvitation is a freshly created invitation. Only Hash is stored, Code is
+// handed to the invitee once.
+type NewInvitation struct {
+	Code string
+	Hash string
+}
+
+func GenerateInvitation() (NewInvitation, error) {
+	code, err := randomToken()
+	if err != nil {
+		return NewInvitation{}, err
+	}
+	return NewInvitation{Code: code, Hash: HashApiKey(code)}, nil
+}
+
+func GenerateChallenge() (string, error) {
+	return randomToken()
+}
+
+// CheckProofOfWork reports whether sha256("<challenge>:<nonce>") starts with
+// at least difficulty zero bits, hashcash style.
+func CheckProofOfWork(challenge, nonce string, difficulty int) bool {
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	zeros := 0
+	for _, b := range sum {
+		zeros += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return zeros >= difficulty
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckProofOfWork(t *testing.T) {
+	challenge, err := GenerateChallenge()
+	assert.NoError(t, err)
+	nonce := 0
+	for !CheckProofOfWork(challenge, strconv.Itoa(nonce), 12) {
+		nonce++
+	}
+	assert.True(t, CheckProofOfWork(challenge, strconv.Itoa(nonce), 8))
+	assert.False(t, CheckProofOfWork(challenge, strconv.Itoa(nonce), 256))
+	assert.True(t, CheckProofOfWork(challenge, "anything", 0))
+}
+
+func TestSignupConfigFromEnv(t *testing.T) {
+	t.Setenv("SIGNUP_MODE", "")
+	t.Setenv("SIGNUP_POW_BITS", "")
+	config, err := SignupConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, SignupConfig{Mode: SignupOpen}, config)
+
+	t.Setenv("SIGNUP_MODE", "invite")
+	t.Setenv("SIGNUP_POW_BITS", "20")
+	config, err = SignupConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, SignupConfig{Mode: SignupInvite, ProofOfWorkBits: 20}, config)
+
+	t.Setenv("SIGNUP_MODE", "closed")
+	_, err = SignupConfigFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("SIGNUP_MODE", "open")
+	t.Setenv("SIGNUP_POW_BITS", "64")
+	_, err = SignupConfigFromEnv()
+	assert.Error(t, err)
+}
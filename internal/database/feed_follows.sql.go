@@ -15,7 +15,7 @@ import (
 const createFeedFollow = `-- name: CreateFeedFollow :one
 INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, created_at, updated_at, user_id, feed_id
+RETURNING id, created_at, updated_at, user_id, feed_id, extract_full_content
 `
 
 type CreateFeedFollowParams struct {
@@ -41,6 +41,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.ExtractFullContent,
 	)
 	return i, err
 }
@@ -59,8 +60,40 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 	return err
 }
 
+const deleteFeedFollowByFeedShortID = `-- name: DeleteFeedFollowByFeedShortID :exec
+DELETE FROM feed_follows
+USING feeds
+WHERE feeds.id = feed_follows.feed_id
+AND feed_follows.user_id = $1
+AND feeds.short_id = $2
+`
+
+type DeleteFeedFollowByFeedShortIDParams struct {
+	UserID  uuid.UUID
+	ShortID int64
+}
+
+func (q *Queries) DeleteFeedFollowByFeedShortID(ctx context.Context, arg DeleteFeedFollowByFeedShortIDParams) error {
+	_, err := q.db.ExecContext(ctx, deleteFeedFollowByFeedShortID, arg.UserID, arg.ShortID)
+	return err
+}
+
+const feedWantsFullContent = `-- name: FeedWantsFullContent :one
+SELECT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_id = $1 AND extract_full_content
+)::bool AS wants_full_content
+`
+
+func (q *Queries) FeedWantsFullContent(ctx context.Context, feedID uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, feedWantsFullContent, feedID)
+	var wants_full_content bool
+	err := row.Scan(&wants_full_content)
+	return wants_full_content, err
+}
+
 const getFeedFollows = `-- name: GetFeedFollows :many
-SELECT id, created_at, updated_at, user_id, feed_id FROM feed_follows WHERE user_id=$1
+SELECT id, created_at, updated_at, user_id, feed_id, extract_full_content FROM feed_follows WHERE user_id=$1
 `
 
 func (q *Queries) GetFeedFollows(ctx context.Context, userID uuid.UUID) ([]FeedFollow, error) {
@@ -78,6 +111,7 @@ func (q *Queries) GetFeedFollows(ctx context.Context, userID uuid.UUID) ([]FeedF
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.ExtractFullContent,
 		); err != nil {
 			return nil, err
 		}
@@ -91,3 +125,50 @@ func (q *Queries) GetFeedFollows(ctx context.Context, userID uuid.UUID) ([]FeedF
 	}
 	return items, nil
 }
+
+const setFeedFollowExtractFullContent = `-- name: SetFeedFollowExtractFullContent :one
+UPDATE feed_follows
+SET extract_full_content = $1,
+updated_at = NOW()
+WHERE id = $2 AND user_id = $3
+RETURNING id, created_at, updated_at, user_id, feed_id, extract_full_content
+`
+
+type SetFeedFollowExtractFullContentParams struct {
+	ExtractFullContent bool
+	ID                 uuid.UUID
+	UserID             uuid.UUID
+}
+
+func (q *Queries) SetFeedFollowExtractFullContent(ctx context.Context, arg SetFeedFollowExtractFullContentParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFollowExtractFullContent, arg.ExtractFullContent, arg.ID, arg.UserID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.ExtractFullContent,
+	)
+	return i, err
+}
+
+const userWantsFullContent = `-- name: UserWantsFullContent :one
+SELECT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_id = $1 AND user_id = $2 AND extract_full_content
+)::bool AS wants_full_content
+`
+
+type UserWantsFullContentParams struct {
+	FeedID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) UserWantsFullContent(ctx context.Context, arg UserWantsFullContentParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, userWantsFullContent, arg.FeedID, arg.UserID)
+	var wants_full_content bool
+	err := row.Scan(&wants_full_content)
+	return wants_full_content, err
+}
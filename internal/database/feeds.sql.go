@@ -7,15 +7,29 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const clearFeedFetchError = `-- name: ClearFeedFetchError :exec
+UPDATE feeds
+SET last_fetch_error = NULL,
+fetch_error_count = 0
+WHERE id = $1
+`
+
+func (q *Queries) ClearFeedFetchError(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, clearFeedFetchError, id)
+	return err
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config
 `
 
 type CreateFeedParams struct {
@@ -24,7 +38,7 @@ type CreateFeedParams struct {
 	UpdatedAt time.Time
 	Name      string
 	Url       string
-	UserID    uuid.UUID
+	UserID    uuid.NullUUID
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -45,12 +59,154 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.ShortID,
+		&i.LastFetchError,
+		&i.FetchErrorCount,
+		&i.ParseWarning,
+		&i.Kind,
+		&i.ScrapeConfig,
+	)
+	return i, err
+}
+
+const createScrapedFeed = `-- name: CreateScrapedFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, kind, scrape_config)
+VALUES ($1, $2, $3, $4, $5, $6, 'scraped', $7)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config
+`
+
+type CreateScrapedFeedParams struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	Url          string
+	UserID       uuid.NullUUID
+	ScrapeConfig json.RawMessage
+}
+
+func (q *Queries) CreateScrapedFeed(ctx context.Context, arg CreateScrapedFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, createScrapedFeed,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		arg.ScrapeConfig,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.ShortID,
+		&i.LastFetchError,
+		&i.FetchErrorCount,
+		&i.ParseWarning,
+		&i.Kind,
+		&i.ScrapeConfig,
+	)
+	return i, err
+}
+
+const deleteFeed = `-- name: DeleteFeed :one
+DELETE FROM feeds WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, deleteFeed, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.ShortID,
+		&i.LastFetchError,
+		&i.FetchErrorCount,
+		&i.ParseWarning,
+		&i.Kind,
+		&i.ScrapeConfig,
+	)
+	return i, err
+}
+
+const deleteUnfollowedUserFeeds = `-- name: DeleteUnfollowedUserFeeds :exec
+DELETE FROM feeds
+WHERE user_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id <> $1
+)
+`
+
+func (q *Queries) DeleteUnfollowedUserFeeds(ctx context.Context, userID uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUnfollowedUserFeeds, userID)
+	return err
+}
+
+const getFeed = `-- name: GetFeed :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config FROM feeds WHERE id = $1
+`
+
+func (q *Queries) GetFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeed, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.ShortID,
+		&i.LastFetchError,
+		&i.FetchErrorCount,
+		&i.ParseWarning,
+		&i.Kind,
+		&i.ScrapeConfig,
+	)
+	return i, err
+}
+
+const getFeedByUrl = `-- name: GetFeedByUrl :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config FROM feeds WHERE url = $1
+`
+
+func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByUrl, url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.ShortID,
+		&i.LastFetchError,
+		&i.FetchErrorCount,
+		&i.ParseWarning,
+		&i.Kind,
+		&i.ScrapeConfig,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -70,6 +226,78 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.ShortID,
+			&i.LastFetchError,
+			&i.FetchErrorCount,
+			&i.ParseWarning,
+			&i.Kind,
+			&i.ScrapeConfig,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsWithHealth = `-- name: GetFeedsWithHealth :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.short_id, feeds.last_fetch_error, feeds.fetch_error_count, feeds.parse_warning, feeds.kind, feeds.scrape_config,
+(SELECT COUNT(*) FROM feed_follows WHERE feed_follows.feed_id = feeds.id) AS follower_count,
+(SELECT COUNT(*) FROM posts WHERE posts.feed_id = feeds.id) AS post_count
+FROM feeds
+WHERE (NOT $1::bool OR feeds.fetch_error_count > 0)
+ORDER BY feeds.fetch_error_count DESC, feeds.short_id
+`
+
+type GetFeedsWithHealthRow struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Name            string
+	Url             string
+	UserID          uuid.NullUUID
+	LastFetchedAt   sql.NullTime
+	ShortID         int64
+	LastFetchError  sql.NullString
+	FetchErrorCount int32
+	ParseWarning    sql.NullString
+	Kind            string
+	ScrapeConfig    json.RawMessage
+	FollowerCount   int64
+	PostCount       int64
+}
+
+func (q *Queries) GetFeedsWithHealth(ctx context.Context, onlyFailing bool) ([]GetFeedsWithHealthRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsWithHealth, onlyFailing)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedsWithHealthRow
+	for rows.Next() {
+		var i GetFeedsWithHealthRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.ShortID,
+			&i.LastFetchError,
+			&i.FetchErrorCount,
+			&i.ParseWarning,
+			&i.Kind,
+			&i.ScrapeConfig,
+			&i.FollowerCount,
+			&i.PostCount,
 		); err != nil {
 			return nil, err
 		}
@@ -85,7 +313,14 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config FROM feeds
+WHERE NOT EXISTS (
+    SELECT 1 FROM websub_subscriptions
+    WHERE websub_subscriptions.feed_id = feeds.id
+    AND websub_subscriptions.state = 'active'
+    AND websub_subscriptions.lease_expires_at > NOW()
+    AND COALESCE(websub_subscriptions.last_push_at, websub_subscriptions.verified_at) > NOW() - INTERVAL '1 day'
+)
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT $1
 `
@@ -107,6 +342,99 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.ShortID,
+			&i.LastFetchError,
+			&i.FetchErrorCount,
+			&i.ParseWarning,
+			&i.Kind,
+			&i.ScrapeConfig,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserCreatedFeeds = `-- name: GetUserCreatedFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config FROM feeds
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetUserCreatedFeeds(ctx context.Context, userID uuid.NullUUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getUserCreatedFeeds, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.ShortID,
+			&i.LastFetchError,
+			&i.FetchErrorCount,
+			&i.ParseWarning,
+			&i.Kind,
+			&i.ScrapeConfig,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserFollowedFeeds = `-- name: GetUserFollowedFeeds :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.short_id, feeds.last_fetch_error, feeds.fetch_error_count, feeds.parse_warning, feeds.kind, feeds.scrape_config FROM feeds
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.short_id
+`
+
+func (q *Queries) GetUserFollowedFeeds(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getUserFollowedFeeds, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.ShortID,
+			&i.LastFetchError,
+			&i.FetchErrorCount,
+			&i.ParseWarning,
+			&i.Kind,
+			&i.ScrapeConfig,
 		); err != nil {
 			return nil, err
 		}
@@ -126,7 +454,7 @@ UPDATE feeds
 SET last_fetched_at = NOW(),
 updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config
 `
 
 func (q *Queries) MarkFeedAsFetched(ctx context.Context, id uuid.UUID) (Feed, error) {
@@ -140,6 +468,74 @@ func (q *Queries) MarkFeedAsFetched(ctx context.Context, id uuid.UUID) (Feed, er
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.ShortID,
+		&i.LastFetchError,
+		&i.FetchErrorCount,
+		&i.ParseWarning,
+		&i.Kind,
+		&i.ScrapeConfig,
+	)
+	return i, err
+}
+
+const recordFeedFetchError = `-- name: RecordFeedFetchError :exec
+UPDATE feeds
+SET last_fetch_error = $2,
+fetch_error_count = fetch_error_count + 1
+WHERE id = $1
+`
+
+type RecordFeedFetchErrorParams struct {
+	ID             uuid.UUID
+	LastFetchError sql.NullString
+}
+
+func (q *Queries) RecordFeedFetchError(ctx context.Context, arg RecordFeedFetchErrorParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedFetchError, arg.ID, arg.LastFetchError)
+	return err
+}
+
+const refreshFeed = `-- name: RefreshFeed :one
+UPDATE feeds
+SET last_fetched_at = NULL,
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, short_id, last_fetch_error, fetch_error_count, parse_warning, kind, scrape_config
+`
+
+func (q *Queries) RefreshFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, refreshFeed, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.ShortID,
+		&i.LastFetchError,
+		&i.FetchErrorCount,
+		&i.ParseWarning,
+		&i.Kind,
+		&i.ScrapeConfig,
 	)
 	return i, err
 }
+
+const setFeedParseWarning = `-- name: SetFeedParseWarning :exec
+UPDATE feeds
+SET parse_warning = $2
+WHERE id = $1
+`
+
+type SetFeedParseWarningParams struct {
+	ID           uuid.UUID
+	ParseWarning sql.NullString
+}
+
+func (q *Queries) SetFeedParseWarning(ctx context.Context, arg SetFeedParseWarningParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedParseWarning, arg.ID, arg.ParseWarning)
+	return err
+}
@@ -6,44 +6,163 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	Prefix       string
+	KeyHash      string
+	FeverKeyHash string
+	LastUsedAt   sql.NullTime
+	ExpiresAt    sql.NullTime
+	RevokedAt    sql.NullTime
+	Scopes       []string
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Name            string
+	Url             string
+	UserID          uuid.NullUUID
+	LastFetchedAt   sql.NullTime
+	ShortID         int64
+	LastFetchError  sql.NullString
+	FetchErrorCount int32
+	ParseWarning    sql.NullString
+	Kind            string
+	ScrapeConfig    json.RawMessage
 }
 
 type FeedFollow struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	UserID             uuid.UUID
+	FeedID             uuid.UUID
+	ExtractFullContent bool
+}
+
+type GreaderToken struct {
+	TokenHash string
+	ApiKeyID  uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type Invitation struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
+	CreatedBy uuid.NullUUID
+	CodeHash  string
+	ExpiresAt sql.NullTime
+	UsedAt    sql.NullTime
+	UsedBy    uuid.NullUUID
 }
 
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Description sql.NullString
-	PublishedAt time.Time
-	Url         string
-	FeedID      uuid.UUID
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            string
+	Description      sql.NullString
+	PublishedAt      time.Time
+	Url              string
+	FeedID           uuid.UUID
+	ShortID          int64
+	Episode          sql.NullInt32
+	Season           sql.NullInt32
+	Content          sql.NullString
+	Authors          []string
+	DescriptionText  sql.NullString
+	ExtractedContent sql.NullString
+	ExtractedAt      sql.NullTime
 }
 
-type User struct {
-	ID        uuid.UUID
+type PostAttachment struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	PostID          uuid.UUID
+	Kind            string
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+	Width           sql.NullInt32
+	Height          sql.NullInt32
+}
+
+type PostCategory struct {
+	PostID uuid.UUID
+	Name   string
+}
+
+type PostState struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	IsRead    bool
+	IsStarred bool
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Name      string
-	ApiKey    string
+}
+
+type RateLimit struct {
+	Key       string
+	Tokens    float64
+	Allowed   bool
+	UpdatedAt time.Time
+}
+
+type Session struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	TokenHash  string
+	CsrfToken  string
+	ExpiresAt  time.Time
+	LastUsedAt sql.NullTime
+	UserAgent  string
+	Ip         string
+}
+
+type SignupChallenge struct {
+	Challenge string
+	ExpiresAt time.Time
+}
+
+type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	FeedToken    string
+	PasswordHash sql.NullString
+	Email        sql.NullString
+	OidcIssuer   sql.NullString
+	OidcSubject  sql.NullString
+	IsAdmin      bool
+	DisabledAt   sql.NullTime
+}
+
+type WebsubSubscription struct {
+	FeedID         uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Hub            string
+	Topic          string
+	Secret         string
+	State          string
+	RequestedAt    time.Time
+	VerifiedAt     sql.NullTime
+	LeaseExpiresAt sql.NullTime
+	LastPushAt     sql.NullTime
 }
@@ -11,23 +11,42 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countUserPosts = `-- name: CountUserPosts :one
+SELECT COUNT(*) FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id=$1
+`
+
+func (q *Queries) CountUserPosts(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserPosts, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, description, published_at, url, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, description, published_at, url, feed_id
+INSERT INTO posts (id, created_at, updated_at, title, description, published_at, url, feed_id, episode, season, content, authors, description_text)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+RETURNING id, created_at, updated_at, title, description, published_at, url, feed_id, short_id, episode, season, content, authors, description_text, extracted_content, extracted_at
 `
 
 type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Description sql.NullString
-	PublishedAt time.Time
-	Url         string
-	FeedID      uuid.UUID
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Title           string
+	Description     sql.NullString
+	PublishedAt     time.Time
+	Url             string
+	FeedID          uuid.UUID
+	Episode         sql.NullInt32
+	Season          sql.NullInt32
+	Content         sql.NullString
+	Authors         []string
+	DescriptionText sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -40,6 +59,11 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.PublishedAt,
 		arg.Url,
 		arg.FeedID,
+		arg.Episode,
+		arg.Season,
+		arg.Content,
+		pq.Array(arg.Authors),
+		arg.DescriptionText,
 	)
 	var i Post
 	err := row.Scan(
@@ -51,62 +75,115 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.PublishedAt,
 		&i.Url,
 		&i.FeedID,
+		&i.ShortID,
+		&i.Episode,
+		&i.Season,
+		&i.Content,
+		pq.Array(&i.Authors),
+		&i.DescriptionText,
+		&i.ExtractedContent,
+		&i.ExtractedAt,
 	)
 	return i, err
 }
 
-const filterUserPosts = `-- name: FilterUserPosts :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.description, posts.published_at, posts.url, posts.feed_id FROM posts
+const getUserPost = `-- name: GetUserPost :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.description, posts.published_at, posts.url, posts.feed_id, posts.short_id, posts.episode, posts.season, posts.content, posts.authors, posts.description_text, posts.extracted_content, posts.extracted_at FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
-WHERE feed_follows.user_id=$1
-AND ($4::text = '' OR posts.title ILIKE '%' || $4 || '%')
-AND ($5::text = '' OR posts.description ILIKE '%' || $5 || '%')
-AND ($6::TIMESTAMP = '0001-01-01' OR posts.published_at <= $6 )
-AND ($7::TIMESTAMP = '0001-01-01' OR posts.published_at >= $7 )
+WHERE posts.id = $1 AND feed_follows.user_id = $2
+`
+
+type GetUserPostParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetUserPost(ctx context.Context, arg GetUserPostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getUserPost, arg.ID, arg.UserID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Description,
+		&i.PublishedAt,
+		&i.Url,
+		&i.FeedID,
+		&i.ShortID,
+		&i.Episode,
+		&i.Season,
+		&i.Content,
+		pq.Array(&i.Authors),
+		&i.DescriptionText,
+		&i.ExtractedContent,
+		&i.ExtractedAt,
+	)
+	return i, err
+}
+
+const getUserPostItems = `-- name: GetUserPostItems :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.description, posts.published_at, posts.url, posts.feed_id, posts.short_id, posts.episode, posts.season, posts.content, posts.authors, posts.description_text, posts.extracted_content, posts.extracted_at, feeds.short_id AS feed_short_id,
+COALESCE(post_states.is_read, false)::bool AS is_read,
+COALESCE(post_states.is_starred, false)::bool AS is_starred
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = $1
+WHERE ($2::bigint = 0 OR posts.short_id > $2)
+AND ($3::bigint = 0 OR posts.short_id < $3)
+AND (cardinality($4::bigint[]) = 0 OR posts.short_id = ANY($4::bigint[]))
 ORDER BY
-  CASE WHEN $8::bool THEN posts.title END asc,
-  CASE WHEN $9::bool THEN posts.title END desc,
-  CASE WHEN $10::bool THEN posts.description END desc,
-  CASE WHEN $11::bool THEN posts.description END asc
-LIMIT $2
-OFFSET $3
+  CASE WHEN $3::bigint = 0 THEN posts.short_id END asc,
+  posts.short_id desc
+LIMIT $5
 `
 
-type FilterUserPostsParams struct {
-	UserID          uuid.UUID
-	Limit           int32
-	Offset          int32
-	Title           string
-	Description     string
-	Before          time.Time
-	After           time.Time
-	TitleAsc        bool
-	TitleDesc       bool
-	DescriptionDesc bool
-	DescriptionAsc  bool
+type GetUserPostItemsParams struct {
+	UserID   uuid.UUID
+	SinceID  int64
+	MaxID    int64
+	ShortIds []int64
+	RowLimit int32
+}
+
+type GetUserPostItemsRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            string
+	Description      sql.NullString
+	PublishedAt      time.Time
+	Url              string
+	FeedID           uuid.UUID
+	ShortID          int64
+	Episode          sql.NullInt32
+	Season           sql.NullInt32
+	Content          sql.NullString
+	Authors          []string
+	DescriptionText  sql.NullString
+	ExtractedContent sql.NullString
+	ExtractedAt      sql.NullTime
+	FeedShortID      int64
+	IsRead           bool
+	IsStarred        bool
 }
 
-func (q *Queries) FilterUserPosts(ctx context.Context, arg FilterUserPostsParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, filterUserPosts,
+func (q *Queries) GetUserPostItems(ctx context.Context, arg GetUserPostItemsParams) ([]GetUserPostItemsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserPostItems,
 		arg.UserID,
-		arg.Limit,
-		arg.Offset,
-		arg.Title,
-		arg.Description,
-		arg.Before,
-		arg.After,
-		arg.TitleAsc,
-		arg.TitleDesc,
-		arg.DescriptionDesc,
-		arg.DescriptionAsc,
+		arg.SinceID,
+		arg.MaxID,
+		pq.Array(arg.ShortIds),
+		arg.RowLimit,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetUserPostItemsRow
 	for rows.Next() {
-		var i Post
+		var i GetUserPostItemsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -116,6 +193,17 @@ func (q *Queries) FilterUserPosts(ctx context.Context, arg FilterUserPostsParams
 			&i.PublishedAt,
 			&i.Url,
 			&i.FeedID,
+			&i.ShortID,
+			&i.Episode,
+			&i.Season,
+			&i.Content,
+			pq.Array(&i.Authors),
+			&i.DescriptionText,
+			&i.ExtractedContent,
+			&i.ExtractedAt,
+			&i.FeedShortID,
+			&i.IsRead,
+			&i.IsStarred,
 		); err != nil {
 			return nil, err
 		}
@@ -131,20 +219,38 @@ func (q *Queries) FilterUserPosts(ctx context.Context, arg FilterUserPostsParams
 }
 
 const getUserPosts = `-- name: GetUserPosts :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.description, posts.published_at, posts.url, posts.feed_id FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.description, posts.published_at, posts.url, posts.feed_id, posts.short_id, posts.episode, posts.season, posts.content, posts.authors, posts.description_text, posts.extracted_content, posts.extracted_at FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 WHERE feed_follows.user_id=$1
-ORDER BY posts.published_at
-LIMIT $2
+AND (NOT $2::bool
+  OR (NOT $3::bool AND (posts.published_at, posts.id) < ($4::TIMESTAMP, $5::uuid))
+  OR ($3::bool AND (posts.published_at, posts.id) > ($4::TIMESTAMP, $5::uuid)))
+ORDER BY
+  CASE WHEN $3::bool THEN posts.published_at END asc,
+  CASE WHEN $3::bool THEN posts.id END asc,
+  posts.published_at desc,
+  posts.id desc
+LIMIT $6
 `
 
 type GetUserPostsParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID            uuid.UUID
+	HasCursor         bool
+	Backward          bool
+	CursorPublishedAt time.Time
+	CursorID          uuid.UUID
+	RowLimit          int32
 }
 
 func (q *Queries) GetUserPosts(ctx context.Context, arg GetUserPostsParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getUserPosts, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getUserPosts,
+		arg.UserID,
+		arg.HasCursor,
+		arg.Backward,
+		arg.CursorPublishedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -161,6 +267,133 @@ func (q *Queries) GetUserPosts(ctx context.Context, arg GetUserPostsParams) ([]P
 			&i.PublishedAt,
 			&i.Url,
 			&i.FeedID,
+			&i.ShortID,
+			&i.Episode,
+			&i.Season,
+			&i.Content,
+			pq.Array(&i.Authors),
+			&i.DescriptionText,
+			&i.ExtractedContent,
+			&i.ExtractedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserStreamItems = `-- name: GetUserStreamItems :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.description, posts.published_at, posts.url, posts.feed_id, posts.short_id, posts.episode, posts.season, posts.content, posts.authors, posts.description_text, posts.extracted_content, posts.extracted_at, feeds.short_id AS feed_short_id, feeds.name AS feed_name, feeds.url AS feed_url,
+COALESCE(post_states.is_read, false)::bool AS is_read,
+COALESCE(post_states.is_starred, false)::bool AS is_starred
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = $1
+WHERE ($2::bigint = 0 OR feeds.short_id = $2)
+AND (NOT $3::bool OR post_states.is_read IS NOT TRUE)
+AND (NOT $4::bool OR post_states.is_read)
+AND (NOT $5::bool OR post_states.is_starred)
+AND ($6::TIMESTAMP = '0001-01-01' OR posts.published_at >= $6)
+AND ($7::TIMESTAMP = '0001-01-01' OR posts.published_at <= $7)
+AND ($8::bigint = 0
+  OR ($9::bool AND posts.short_id > $8)
+  OR (NOT $9::bool AND posts.short_id < $8))
+AND (cardinality($10::bigint[]) = 0 OR posts.short_id = ANY($10::bigint[]))
+ORDER BY
+  CASE WHEN $9::bool THEN posts.short_id END asc,
+  posts.short_id desc
+LIMIT $11
+`
+
+type GetUserStreamItemsParams struct {
+	UserID       uuid.UUID
+	FeedShortID  int64
+	OnlyUnread   bool
+	OnlyRead     bool
+	OnlyStarred  bool
+	NewerThan    time.Time
+	OlderThan    time.Time
+	Continuation int64
+	OldestFirst  bool
+	ShortIds     []int64
+	RowLimit     int32
+}
+
+type GetUserStreamItemsRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            string
+	Description      sql.NullString
+	PublishedAt      time.Time
+	Url              string
+	FeedID           uuid.UUID
+	ShortID          int64
+	Episode          sql.NullInt32
+	Season           sql.NullInt32
+	Content          sql.NullString
+	Authors          []string
+	DescriptionText  sql.NullString
+	ExtractedContent sql.NullString
+	ExtractedAt      sql.NullTime
+	FeedShortID      int64
+	FeedName         string
+	FeedUrl          string
+	IsRead           bool
+	IsStarred        bool
+}
+
+func (q *Queries) GetUserStreamItems(ctx context.Context, arg GetUserStreamItemsParams) ([]GetUserStreamItemsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserStreamItems,
+		arg.UserID,
+		arg.FeedShortID,
+		arg.OnlyUnread,
+		arg.OnlyRead,
+		arg.OnlyStarred,
+		arg.NewerThan,
+		arg.OlderThan,
+		arg.Continuation,
+		arg.OldestFirst,
+		pq.Array(arg.ShortIds),
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserStreamItemsRow
+	for rows.Next() {
+		var i GetUserStreamItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Description,
+			&i.PublishedAt,
+			&i.Url,
+			&i.FeedID,
+			&i.ShortID,
+			&i.Episode,
+			&i.Season,
+			&i.Content,
+			pq.Array(&i.Authors),
+			&i.DescriptionText,
+			&i.ExtractedContent,
+			&i.ExtractedAt,
+			&i.FeedShortID,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.IsRead,
+			&i.IsStarred,
 		); err != nil {
 			return nil, err
 		}
@@ -174,3 +407,81 @@ func (q *Queries) GetUserPosts(ctx context.Context, arg GetUserPostsParams) ([]P
 	}
 	return items, nil
 }
+
+const getUserUnreadCounts = `-- name: GetUserUnreadCounts :many
+SELECT feeds.short_id AS feed_short_id, COUNT(*) AS count, MAX(posts.published_at)::TIMESTAMP AS newest_published_at
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND post_states.is_read IS NOT TRUE
+GROUP BY feeds.short_id
+`
+
+type GetUserUnreadCountsRow struct {
+	FeedShortID       int64
+	Count             int64
+	NewestPublishedAt time.Time
+}
+
+func (q *Queries) GetUserUnreadCounts(ctx context.Context, userID uuid.UUID) ([]GetUserUnreadCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserUnreadCounts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserUnreadCountsRow
+	for rows.Next() {
+		var i GetUserUnreadCountsRow
+		if err := rows.Scan(&i.FeedShortID, &i.Count, &i.NewestPublishedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setPostExtractedContent = `-- name: SetPostExtractedContent :one
+UPDATE posts
+SET extracted_content = $1,
+extracted_at = NOW(),
+updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, title, description, published_at, url, feed_id, short_id, episode, season, content, authors, description_text, extracted_content, extracted_at
+`
+
+type SetPostExtractedContentParams struct {
+	ExtractedContent sql.NullString
+	ID               uuid.UUID
+}
+
+func (q *Queries) SetPostExtractedContent(ctx context.Context, arg SetPostExtractedContentParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, setPostExtractedContent, arg.ExtractedContent, arg.ID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Description,
+		&i.PublishedAt,
+		&i.Url,
+		&i.FeedID,
+		&i.ShortID,
+		&i.Episode,
+		&i.Season,
+		&i.Content,
+		pq.Array(&i.Authors),
+		&i.DescriptionText,
+		&i.ExtractedContent,
+		&i.ExtractedAt,
+	)
+	return i, err
+}
@@ -7,15 +7,61 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const createOIDCUser = `-- name: CreateOIDCUser :one
+    INSERT INTO users (id, created_at, updated_at, name, email, oidc_issuer, oidc_subject, feed_token)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    RETURNING id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at
+`
+
+type CreateOIDCUserParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string
+	Email       sql.NullString
+	OidcIssuer  sql.NullString
+	OidcSubject sql.NullString
+	FeedToken   string
+}
+
+func (q *Queries) CreateOIDCUser(ctx context.Context, arg CreateOIDCUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createOIDCUser,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+		arg.Email,
+		arg.OidcIssuer,
+		arg.OidcSubject,
+		arg.FeedToken,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
-    INSERT INTO users (id, created_at, updated_at, name,  api_key)
-    VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea),'hex'))
-    RETURNING id, created_at, updated_at, name, api_key
+    INSERT INTO users (id, created_at, updated_at, name, feed_token)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at
 `
 
 type CreateUserParams struct {
@@ -23,6 +69,7 @@ type CreateUserParams struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Name      string
+	FeedToken string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -31,31 +78,433 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
+		arg.FeedToken,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const deleteUser = `-- name: DeleteUser :one
+    DELETE FROM users WHERE id = $1
+    RETURNING id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, deleteUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getUserByFeedToken = `-- name: GetUserByFeedToken :one
+    SELECT id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at FROM users WHERE feed_token =$1 AND disabled_at IS NULL
+`
+
+func (q *Queries) GetUserByFeedToken(ctx context.Context, feedToken string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByFeedToken, feedToken)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getUserByFeverKeyHash = `-- name: GetUserByFeverKeyHash :one
+    SELECT users.id, users.created_at, users.updated_at, users.name, users.feed_token, users.password_hash, users.email, users.oidc_issuer, users.oidc_subject, users.is_admin, users.disabled_at FROM users
+    JOIN api_keys ON api_keys.user_id = users.id
+    WHERE api_keys.fever_key_hash =$1
+    AND users.disabled_at IS NULL
+    AND api_keys.revoked_at IS NULL
+    AND (api_keys.expires_at IS NULL OR api_keys.expires_at > NOW())
+`
+
+func (q *Queries) GetUserByFeverKeyHash(ctx context.Context, feverKeyHash string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByFeverKeyHash, feverKeyHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+    SELECT id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at FROM users WHERE id =$1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getUserByName = `-- name: GetUserByName :one
+    SELECT id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at FROM users WHERE lower(name) = lower($1)
+`
+
+func (q *Queries) GetUserByName(ctx context.Context, name string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByName, name)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getUserByNameWithPassword = `-- name: GetUserByNameWithPassword :one
+    SELECT id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at FROM users WHERE name =$1 AND password_hash IS NOT NULL
+`
+
+func (q *Queries) GetUserByNameWithPassword(ctx context.Context, name string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByNameWithPassword, name)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getUserByOIDCSubject = `-- name: GetUserByOIDCSubject :one
+    SELECT id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at FROM users WHERE oidc_issuer =$1 AND oidc_subject =$2
+`
+
+type GetUserByOIDCSubjectParams struct {
+	OidcIssuer  sql.NullString
+	OidcSubject sql.NullString
+}
+
+func (q *Queries) GetUserByOIDCSubject(ctx context.Context, arg GetUserByOIDCSubjectParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByOIDCSubject, arg.OidcIssuer, arg.OidcSubject)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const linkUserOIDC = `-- name: LinkUserOIDC :one
+    UPDATE users
+    SET oidc_issuer = $1,
+    oidc_subject = $2,
+    email = $3,
+    updated_at = NOW()
+    WHERE id = $4
+    RETURNING id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at
+`
+
+type LinkUserOIDCParams struct {
+	OidcIssuer  sql.NullString
+	OidcSubject sql.NullString
+	Email       sql.NullString
+	ID          uuid.UUID
+}
+
+func (q *Queries) LinkUserOIDC(ctx context.Context, arg LinkUserOIDCParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, linkUserOIDC,
+		arg.OidcIssuer,
+		arg.OidcSubject,
+		arg.Email,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const listUsers = `-- name: ListUsers :many
+    SELECT id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at FROM users ORDER BY created_at, id
+`
+
+func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.FeedToken,
+			&i.PasswordHash,
+			&i.Email,
+			&i.OidcIssuer,
+			&i.OidcSubject,
+			&i.IsAdmin,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const regenerateFeedToken = `-- name: RegenerateFeedToken :one
+    UPDATE users
+    SET feed_token = $2,
+    updated_at = NOW()
+    WHERE id = $1
+    RETURNING id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at
+`
+
+type RegenerateFeedTokenParams struct {
+	ID        uuid.UUID
+	FeedToken string
+}
+
+func (q *Queries) RegenerateFeedToken(ctx context.Context, arg RegenerateFeedTokenParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, regenerateFeedToken, arg.ID, arg.FeedToken)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const setUserAdmin = `-- name: SetUserAdmin :one
+    UPDATE users
+    SET is_admin = $1,
+    updated_at = NOW()
+    WHERE id = $2
+    RETURNING id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at
+`
+
+type SetUserAdminParams struct {
+	IsAdmin bool
+	ID      uuid.UUID
+}
+
+func (q *Queries) SetUserAdmin(ctx context.Context, arg SetUserAdminParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserAdmin, arg.IsAdmin, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
 	)
+	return i, err
+}
+
+const setUserDisabled = `-- name: SetUserDisabled :one
+    UPDATE users
+    SET disabled_at = CASE WHEN $1::bool THEN COALESCE(disabled_at, NOW()) END,
+    updated_at = NOW()
+    WHERE id = $2
+    RETURNING id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at
+`
+
+type SetUserDisabledParams struct {
+	Disabled bool
+	ID       uuid.UUID
+}
+
+func (q *Queries) SetUserDisabled(ctx context.Context, arg SetUserDisabledParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserDisabled, arg.Disabled, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
-		&i.ApiKey,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
 	)
 	return i, err
 }
 
-const getUserByApikey = `-- name: GetUserByApikey :one
-    SELECT id, created_at, updated_at, name, api_key FROM users WHERE api_key =$1
+const setUserPassword = `-- name: SetUserPassword :one
+    UPDATE users
+    SET password_hash = $1,
+    updated_at = NOW()
+    WHERE id = $2
+    RETURNING id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at
 `
 
-func (q *Queries) GetUserByApikey(ctx context.Context, apiKey string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByApikey, apiKey)
+type SetUserPasswordParams struct {
+	PasswordHash sql.NullString
+	ID           uuid.UUID
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserPassword, arg.PasswordHash, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const updateUserName = `-- name: UpdateUserName :one
+    UPDATE users
+    SET name = $1,
+    updated_at = NOW()
+    WHERE id = $2
+    RETURNING id, created_at, updated_at, name, feed_token, password_hash, email, oidc_issuer, oidc_subject, is_admin, disabled_at
+`
+
+type UpdateUserNameParams struct {
+	Name string
+	ID   uuid.UUID
+}
+
+func (q *Queries) UpdateUserName(ctx context.Context, arg UpdateUserNameParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserName, arg.Name, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
-		&i.ApiKey,
+		&i.FeedToken,
+		&i.PasswordHash,
+		&i.Email,
+		&i.OidcIssuer,
+		&i.OidcSubject,
+		&i.IsAdmin,
+		&i.DisabledAt,
 	)
 	return i, err
 }
@@ -0,0 +1,47 @@
+// Package extract fetches the page a post links to and pulls out the article,
+// for feeds that only publish a teaser.
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	readability "github.com/go-shiori/go-readability"
+	"github.com/leguzman/rss-project/internal/fetch"
+	"github.com/leguzman/rss-project/internal/sanitize"
+)
+
+type Article struct {
+	// Content is the sanitized article markup.
+	Content string
+	Text    string
+}
+
+// Extract downloads pageURL with client and returns its main content, found
+// the way reader modes do by scoring the blocks of the page.
+func Extract(ctx context.Context, client *fetch.Client, pageURL string) (Article, error) {
+	if !strings.HasPrefix(pageURL, "http://") && !strings.HasPrefix(pageURL, "https://") {
+		return Article{}, fmt.Errorf("not a web page: %q", pageURL)
+	}
+	resp, err := client.Get(ctx, pageURL, "text/html,application/xhtml+xml")
+	if err != nil {
+		return Article{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Article{}, fmt.Errorf("page returned status %d", resp.StatusCode)
+	}
+	// Links in the article are relative to where redirects ended up.
+	finalURL := resp.URL
+	article, err := readability.FromReader(bytes.NewReader(resp.Body), finalURL)
+	if err != nil {
+		return Article{}, err
+	}
+	content := sanitize.HTML(article.Content, finalURL.String())
+	if strings.TrimSpace(content) == "" {
+		return Article{}, errors.New("no article found on the page")
+	}
+	return Article{Content: content, Text: sanitize.Text(content)}, nil
+}
@@ -0,0 +1,57 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leguzman/rss-project/internal/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html><head><title>A long read</title></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<article>
+<h1>A long read</h1>
+%s
+<p><img src="/images/chart.png" alt="Chart"></p>
+<script>track()</script>
+</article>
+<footer>Copyright</footer>
+</body></html>`
+
+func TestExtract(t *testing.T) {
+	paragraphs := strings.Repeat("<p>The whole story, told across many sentences so the scoring has something to go on, with commas, clauses, and detail.</p>\n", 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/post":
+			http.Redirect(w, r, "/articles/post", http.StatusFound)
+		case "/articles/post":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, articlePage, paragraphs)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := fetch.New(fetch.Config{MaxBodySize: 1 << 20, MaxRedirects: 1, AllowPrivateNetworks: true})
+	article, err := Extract(context.Background(), client, server.URL+"/post")
+	assert.NoError(t, err)
+	assert.Contains(t, article.Content, "The whole story")
+	assert.Contains(t, article.Content, server.URL+"/images/chart.png")
+	assert.NotContains(t, article.Content, "track()")
+	assert.NotContains(t, article.Content, "Copyright")
+	assert.Contains(t, article.Text, "The whole story")
+	assert.NotContains(t, article.Text, "<p>")
+
+	_, err = Extract(context.Background(), client, server.URL+"/missing")
+	assert.Error(t, err)
+	_, err = Extract(context.Background(), client, "ftp://example.com/post")
+	assert.Error(t, err)
+}
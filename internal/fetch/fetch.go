@@ -0,0 +1,349 @@
+// Package fetch is the HTTP client for every request to a URL users gave,
+// feeds, the pages posts link to and WebSub hubs. It caps what a response
+// may cost and refuses to reach private networks, which would let anyone
+// adding a feed probe the machines around the server.
+package fetch
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+var (
+	ErrBlocked  = errors.New("address is in a private network")
+	ErrTooLarge = errors.New("response is too large")
+)
+
+type Config struct {
+	UserAgent string
+	// Timeout covers the whole request, redirects and body included.
+	Timeout     time.Duration
+	MaxBodySize int64
+	// MaxRedirects is how many redirects are followed, 0 for none.
+	MaxRedirects int
+	// Proxy is the proxy requests go through, HTTP_PROXY and the like when
+	// nil.
+	Proxy *url.URL
+	// AllowPrivateNetworks lets requests reach loopback, private and
+	// link-local addresses, for running against local feeds.
+	AllowPrivateNetworks bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		UserAgent:    "rss-project (+https://github.com/leguzman/rss-project)",
+		Timeout:      15 * time.Second,
+		MaxBodySize:  10 << 20,
+		MaxRedirects: 5,
+	}
+}
+
+// ConfigFromEnv reads FETCH_USER_AGENT, FETCH_TIMEOUT_SECONDS,
+// FETCH_MAX_BODY_BYTES, FETCH_MAX_REDIRECTS, FETCH_PROXY_URL and
+// FETCH_ALLOW_PRIVATE_NETWORKS over DefaultConfig.
+func ConfigFromEnv() (Config, error) {
+	config := DefaultConfig()
+	if value := os.Getenv("FETCH_USER_AGENT"); value != "" {
+		config.UserAgent = value
+	}
+	if value := os.Getenv("FETCH_TIMEOUT_SECONDS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return Config{}, errors.New("FETCH_TIMEOUT_SECONDS must be a positive number of seconds")
+		}
+		config.Timeout = time.Duration(n) * time.Second
+	}
+	if value := os.Getenv("FETCH_MAX_BODY_BYTES"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 {
+			return Config{}, errors.New("FETCH_MAX_BODY_BYTES must be a positive number of bytes")
+		}
+		config.MaxBodySize = n
+	}
+	if value := os.Getenv("FETCH_MAX_REDIRECTS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return Config{}, errors.New("FETCH_MAX_REDIRECTS must be a number of redirects")
+		}
+		config.MaxRedirects = n
+	}
+	if value := os.Getenv("FETCH_PROXY_URL"); value != "" {
+		proxy, err := url.Parse(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid FETCH_PROXY_URL: %v", err)
+		}
+		config.Proxy = proxy
+	}
+	if value := os.Getenv("FETCH_ALLOW_PRIVATE_NETWORKS"); value != "" {
+		allow, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, errors.New("FETCH_ALLOW_PRIVATE_NETWORKS must be true or false")
+		}
+		config.AllowPrivateNetworks = allow
+	}
+	return config, nil
+}
+
+// Client is safe for concurrent use, and meant to be shared so connections
+// are reused.
+type Client struct {
+	config Config
+	client *http.Client
+}
+
+// proxyDial is where the transport was told to send the request a context
+// belongs to. Only the proxy is trusted wherever it is, requests sent
+// directly to its address are checked like any other.
+type proxyDial struct {
+	mu   sync.Mutex
+	addr string
+}
+
+type proxyDialContextKey struct{}
+
+func (p *proxyDial) set(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addr = addr
+}
+
+// isProxy reports whether addr is the proxy of the request ctx belongs to.
+func isProxy(ctx context.Context, addr string) bool {
+	p, ok := ctx.Value(proxyDialContextKey{}).(*proxyDial)
+	if !ok {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.addr != "" && p.addr == addr
+}
+
+// Response is a response with its body read and decompressed.
+type Response struct {
+	// URL is where the request ended up after redirects.
+	URL        *url.URL
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func New(config Config) *Client {
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != nil {
+		proxy = http.ProxyURL(config.Proxy)
+	}
+	return newClient(config, proxy)
+}
+
+func newClient(config Config, proxy func(*http.Request) (*url.URL, error)) *Client {
+	c := &Client{config: config}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	guarded := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second, Control: checkDial}
+	transport := &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			proxyURL, err := proxy(req)
+			if dial, ok := req.Context().Value(proxyDialContextKey{}).(*proxyDial); ok {
+				if err == nil && proxyURL != nil {
+					dial.set(hostPort(proxyURL))
+				} else {
+					dial.set("")
+				}
+			}
+			if err != nil || proxyURL == nil {
+				return proxyURL, err
+			}
+			if config.AllowPrivateNetworks {
+				return proxyURL, nil
+			}
+			// The proxy is what dials the host, so it is looked up here
+			// instead.
+			return proxyURL, checkHost(req.Context(), req.URL.Hostname())
+		},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if config.AllowPrivateNetworks || isProxy(ctx, addr) {
+				return dialer.DialContext(ctx, network, addr)
+			}
+			// Checked once resolved, so a name can't point somewhere else
+			// between the check and the connection.
+			return guarded.DialContext(ctx, network, addr)
+		},
+		// Bodies are decompressed in Do, to cap their decompressed size and
+		// take brotli too.
+		DisableCompression:    true,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	c.client = &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > config.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", config.MaxRedirects)
+			}
+			return checkScheme(req.URL)
+		},
+	}
+	return c
+}
+
+// Get fetches url, sending accept as the Accept header when set.
+func (c *Client) Get(ctx context.Context, url, accept string) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	return c.Do(req)
+}
+
+// Do sends req and reads the response, failing with ErrTooLarge past the
+// body size limit. Responses of any status are returned.
+func (c *Client) Do(req *http.Request) (*Response, error) {
+	err := checkScheme(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	// Redirects keep the context, the proxy is set again for each of them.
+	req = req.WithContext(context.WithValue(req.Context(), proxyDialContextKey{}, &proxyDial{}))
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := decode(resp)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, c.config.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > c.config.MaxBodySize {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrTooLarge, c.config.MaxBodySize)
+	}
+	header := resp.Header.Clone()
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	return &Response{
+		URL:        resp.Request.URL,
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       data,
+	}, nil
+}
+
+func decode(resp *http.Response) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	return nil
+}
+
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// checkDial runs once the address to connect to is resolved.
+func checkDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if IsPrivate(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlocked, addrPort.Addr())
+	}
+	return nil
+}
+
+func checkHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if IsPrivate(addr) {
+			return fmt.Errorf("%w: %s is %s", ErrBlocked, host, addr)
+		}
+	}
+	return nil
+}
+
+// privateNetworks are the networks not reachable from the internet, or not
+// meant to be: loopback, RFC 1918, carrier-grade NAT, link-local (cloud
+// metadata services), benchmarking, multicast and reserved ranges. NAT64 and
+// 6to4 addresses embed an IPv4 address that could be any of those, so they
+// are refused whole.
+var privateNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// IsPrivate reports whether addr is in a network requests must not reach.
+func IsPrivate(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, network := range privateNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func localClient(config Config) *Client {
+	config.AllowPrivateNetworks = true
+	return New(config)
+}
+
+func TestIsPrivate(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "172.20.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fd00::1", "fe80::1", "::ffff:127.0.0.1", "64:ff9b::7f00:1", "64:ff9b:1::a00:1", "2002:7f00:1::1"} {
+		assert.True(t, IsPrivate(netip.MustParseAddr(addr)), addr)
+	}
+	for _, addr := range []string{"1.1.1.1", "93.184.216.34", "172.32.0.1", "2606:4700:4700::1111"} {
+		assert.False(t, IsPrivate(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestBlocksPrivateNetworks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+	client := New(DefaultConfig())
+	_, err := client.Get(context.Background(), server.URL, "")
+	assert.ErrorIs(t, err, ErrBlocked)
+	_, err = client.Get(context.Background(), strings.Replace(server.URL, "127.0.0.1", "localhost", 1), "")
+	assert.ErrorIs(t, err, ErrBlocked)
+	_, err = client.Get(context.Background(), "file:///etc/passwd", "")
+	assert.ErrorContains(t, err, "unsupported URL scheme")
+}
+
+func TestCompression(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip, br", r.Header.Get("Accept-Encoding"))
+		assert.Equal(t, "test agent", r.Header.Get("User-Agent"))
+		body := &bytes.Buffer{}
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			writer := gzip.NewWriter(body)
+			writer.Write([]byte("zipped"))
+			writer.Close()
+		case "/br":
+			w.Header().Set("Content-Encoding", "br")
+			writer := brotli.NewWriter(body)
+			writer.Write([]byte("brotli"))
+			writer.Close()
+		default:
+			body.WriteString("plain")
+		}
+		w.Write(body.Bytes())
+	}))
+	defer server.Close()
+	config := DefaultConfig()
+	config.UserAgent = "test agent"
+	client := localClient(config)
+	for path, want := range map[string]string{"/gzip": "zipped", "/br": "brotli", "/": "plain"} {
+		resp, err := client.Get(context.Background(), server.URL+path, "")
+		if assert.NoError(t, err, path) {
+			assert.Equal(t, want, string(resp.Body))
+			assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			// Small on the wire, past the limit once decompressed.
+			w.Header().Set("Content-Encoding", "gzip")
+			writer := gzip.NewWriter(w)
+			writer.Write([]byte(strings.Repeat("a", 2048)))
+			writer.Close()
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/once":
+			http.Redirect(w, r, "/small", http.StatusFound)
+		default:
+			w.Write([]byte("small"))
+		}
+	}))
+	defer server.Close()
+	config := DefaultConfig()
+	config.MaxBodySize = 1024
+	config.MaxRedirects = 2
+	client := localClient(config)
+
+	_, err := client.Get(context.Background(), server.URL+"/big", "")
+	assert.ErrorIs(t, err, ErrTooLarge)
+	_, err = client.Get(context.Background(), server.URL+"/loop", "")
+	assert.ErrorContains(t, err, "stopped after 2 redirects")
+	resp, err := client.Get(context.Background(), server.URL+"/once", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "small", string(resp.Body))
+		assert.Equal(t, "/small", resp.URL.Path)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	proxied := ""
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("from proxy"))
+	}))
+	defer proxy.Close()
+	config := DefaultConfig()
+	config.Proxy, _ = url.Parse(proxy.URL)
+	client := New(config)
+
+	// The proxy is trusted on loopback, the hosts it is asked for aren't.
+	_, err := client.Get(context.Background(), "http://127.0.0.1/feed.xml", "")
+	assert.ErrorIs(t, err, ErrBlocked)
+	resp, err := client.Get(context.Background(), "http://93.184.216.34/feed.xml", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "from proxy", string(resp.Body))
+		assert.Equal(t, "http://93.184.216.34/feed.xml", proxied)
+	}
+}
+
+func TestDirectRequestToProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from proxy"))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	// Like a NO_PROXY entry, the proxy's own address isn't proxied.
+	client := newClient(DefaultConfig(), func(req *http.Request) (*url.URL, error) {
+		if req.URL.Host == proxyURL.Host {
+			return nil, nil
+		}
+		return proxyURL, nil
+	})
+
+	_, err := client.Get(context.Background(), "http://93.184.216.34/feed.xml", "")
+	assert.NoError(t, err)
+	_, err = client.Get(context.Background(), proxy.URL+"/admin", "")
+	assert.ErrorIs(t, err, ErrBlocked)
+}
@@ -0,0 +1,89 @@
+package ingest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// xmlDeclEncoding finds the encoding in an XML declaration, readable as ASCII
+// in every encoding feeds use short of UTF-16, which comes with a BOM.
+var xmlDeclEncoding = regexp.MustCompile(`^\s*<\?xml[^>]*?\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// toUTF8 transcodes a feed to UTF-8. The encoding comes from a byte order
+// mark, then the charset of contentType (the Content-Type header it was
+// served with, may be empty), then the encoding in the XML declaration,
+// which is the order RFC 7303 gives them.
+func toUTF8(data []byte, contentType string) ([]byte, error) {
+	data, label := stripBOM(data)
+	if label == "" {
+		label = contentTypeCharset(contentType)
+		// Servers often claim UTF-8 for everything, the declaration of a
+		// feed that isn't knows better.
+		if isUTF8Label(label) && !utf8.Valid(data) {
+			label = ""
+		}
+	}
+	if label == "" {
+		head := data
+		if len(head) > 1024 {
+			head = head[:1024]
+		}
+		if match := xmlDeclEncoding.FindSubmatch(head); match != nil {
+			label = string(match[1])
+		}
+	}
+	if label == "" || isUTF8Label(label) {
+		return data, nil
+	}
+	reader, err := charset.NewReaderLabel(label, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	data, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode %s: %w", label, err)
+	}
+	return data, nil
+}
+
+// stripBOM removes a byte order mark and returns the encoding it stands for.
+func stripBOM(data []byte) ([]byte, string) {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return data[len(utf8BOM):], "utf-8"
+	case bytes.HasPrefix(data, utf16LEBOM):
+		return data[len(utf16LEBOM):], "utf-16le"
+	case bytes.HasPrefix(data, utf16BEBOM):
+		return data[len(utf16BEBOM):], "utf-16be"
+	}
+	return data, ""
+}
+
+func contentTypeCharset(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(params["charset"])
+}
+
+func isUTF8Label(label string) bool {
+	label = strings.ToLower(label)
+	return label == "utf-8" || label == "utf8"
+}
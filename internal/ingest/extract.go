@@ -0,0 +1,93 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/extract"
+	"github.com/leguzman/rss-project/internal/fetch"
+)
+
+const (
+	extractTimeout   = 30 * time.Second
+	extractQueueSize = 1000
+)
+
+// Extractor stores the articles posts link to in the background, so slow
+// pages don't hold up storing the feed. At most workers pages are extracted
+// at once, workers stop when the queue is empty.
+type Extractor struct {
+	queue   chan database.Post
+	workers chan struct{}
+	extract func(database.Post)
+}
+
+func NewExtractor(db *database.Queries, client *fetch.Client, workers int) *Extractor {
+	return &Extractor{
+		queue:   make(chan database.Post, extractQueueSize),
+		workers: make(chan struct{}, workers),
+		extract: func(post database.Post) {
+			extractPost(db, client, post)
+		},
+	}
+}
+
+// Enqueue queues post for extraction, dropping it when the queue is full.
+func (e *Extractor) Enqueue(post database.Post) {
+	select {
+	case e.queue <- post:
+	default:
+		log.Printf("Extraction queue full, skipping %s", post.Url)
+		return
+	}
+	select {
+	case e.workers <- struct{}{}:
+		go e.work()
+	default:
+		// The running workers will get to it.
+	}
+}
+
+func (e *Extractor) work() {
+	for {
+		select {
+		case post := <-e.queue:
+			e.extract(post)
+			continue
+		default:
+		}
+		<-e.workers
+		// A post queued while leaving found every worker busy, take it
+		// unless another worker started since.
+		if len(e.queue) == 0 {
+			return
+		}
+		select {
+		case e.workers <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// extractPost stores the article the post links to, for follows that asked
+// for more than the teaser the feed publishes.
+func extractPost(db *database.Queries, client *fetch.Client, post database.Post) {
+	ctx, cancel := context.WithTimeout(context.Background(), extractTimeout)
+	defer cancel()
+	article, err := extract.Extract(ctx, client, post.Url)
+	if err != nil {
+		log.Printf("Couldn't extract %s: %v", post.Url, err)
+		return
+	}
+	_, err = db.SetPostExtractedContent(ctx, database.SetPostExtractedContentParams{
+		ExtractedContent: sql.NullString{String: article.Content, Valid: true},
+		ID:               post.ID,
+	})
+	if err != nil {
+		log.Println("Couldn't store extracted content: ", err)
+	}
+}
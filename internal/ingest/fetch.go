@@ -0,0 +1,35 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leguzman/rss-project/internal/fetch"
+)
+
+// feedAccept prefers feeds, but scraped feeds are plain pages.
+const feedAccept = "application/rss+xml, application/xml;q=0.9, text/xml;q=0.9, text/html;q=0.8, */*;q=0.5"
+
+// HTTPFetcher downloads feeds from the web.
+type HTTPFetcher struct {
+	Client *fetch.Client
+}
+
+func NewHTTPFetcher(client *fetch.Client) *HTTPFetcher {
+	return &HTTPFetcher{Client: client}
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) (Document, error) {
+	resp, err := f.Client.Get(ctx, url, feedAccept)
+	if err != nil {
+		return Document{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Document{}, fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+	return Document{
+		URL:         resp.URL.String(),
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        resp.Body,
+	}, nil
+}
@@ -0,0 +1,191 @@
+// Package ingest reads feeds into posts. A Fetcher downloads the document a
+// feed lives at, the Parser registered for the kind of feed turns it into
+// Items and a Sink stores them. Items pushed by WebSub hubs skip the
+// Fetcher.
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+)
+
+// Kinds of feeds with a Parser registered by New.
+const (
+	FeedKindRSS = "rss"
+	// FeedKindScraped feeds are web pages read with a ScrapeConfig.
+	FeedKindScraped = "scraped"
+)
+
+// Feed is a feed as ingestion needs it.
+type Feed struct {
+	ID   uuid.UUID
+	Name string
+	URL  string
+	Kind string
+	// ScrapeConfig is the ScrapeConfig of a scraped feed, as stored.
+	ScrapeConfig json.RawMessage
+}
+
+func FeedFromDB(feed database.Feed) Feed {
+	return Feed{
+		ID:           feed.ID,
+		Name:         feed.Name,
+		URL:          feed.Url,
+		Kind:         feed.Kind,
+		ScrapeConfig: feed.ScrapeConfig,
+	}
+}
+
+// Item is a post found in a feed, whatever the format of the feed.
+type Item struct {
+	Title string
+	Link  string
+	// Description and Content are HTML as the feed has it, sanitized when
+	// stored.
+	Description string
+	Content     string
+	// PublishedAt is the zero time when the feed doesn't say.
+	PublishedAt time.Time
+	Authors     []string
+	Categories  []string
+	// Episode and Season number podcast episodes, 0 when they aren't.
+	Episode     int32
+	Season      int32
+	Attachments []Attachment
+}
+
+// Attachment is a media file of an item. Kind is "enclosure", "media",
+// "thumbnail" or "image" (the episode art). Unknown sizes and durations are
+// 0.
+type Attachment struct {
+	Kind     string
+	URL      string
+	MimeType string
+	Length   int64
+	Duration int32
+	Width    int32
+	Height   int32
+}
+
+// Document is a downloaded feed.
+type Document struct {
+	// URL is where the document ended up after redirects.
+	URL         string
+	ContentType string
+	Body        []byte
+}
+
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (Document, error)
+}
+
+type Parser interface {
+	Parse(feed Feed, doc Document) (Result, error)
+}
+
+type Sink interface {
+	// Store saves the items of feed not stored yet, returning how many that
+	// were.
+	Store(ctx context.Context, feed Feed, items []Item) (int, error)
+}
+
+// Result is what reading a feed found.
+type Result struct {
+	Items []Item
+	// Warning is set when a malformed feed had to be repaired.
+	Warning string
+	// Hub and Topic are where to subscribe to the feed with WebSub, empty
+	// when it has no hub.
+	Hub   string
+	Topic string
+	// Created is how many of Items were new, once stored.
+	Created int
+}
+
+type Ingester struct {
+	fetcher Fetcher
+	sink    Sink
+	parsers map[string]Parser
+}
+
+// New returns an Ingester for RSS and scraped feeds. Other kinds of feeds
+// can be added with Register.
+func New(fetcher Fetcher, sink Sink) *Ingester {
+	return &Ingester{
+		fetcher: fetcher,
+		sink:    sink,
+		parsers: map[string]Parser{
+			FeedKindRSS:     RSSParser{},
+			FeedKindScraped: ScrapeParser{},
+		},
+	}
+}
+
+// Register reads feeds of kind with parser from now on. It must be called
+// before the Ingester is used.
+func (in *Ingester) Register(kind string, parser Parser) {
+	in.parsers[kind] = parser
+}
+
+// Fetch downloads the document at url without parsing it.
+func (in *Ingester) Fetch(ctx context.Context, url string) (Document, error) {
+	return in.fetcher.Fetch(ctx, url)
+}
+
+// Read fetches and parses feed without storing anything.
+func (in *Ingester) Read(ctx context.Context, feed Feed) (Result, error) {
+	parser, err := in.parser(feed)
+	if err != nil {
+		return Result{}, err
+	}
+	doc, err := in.fetcher.Fetch(ctx, feed.URL)
+	if err != nil {
+		return Result{}, err
+	}
+	return parser.Parse(feed, doc)
+}
+
+// Ingest fetches, parses and stores feed.
+func (in *Ingester) Ingest(ctx context.Context, feed Feed) (Result, error) {
+	result, err := in.Read(ctx, feed)
+	if err != nil {
+		return Result{}, err
+	}
+	return in.store(ctx, feed, result)
+}
+
+// IngestDocument parses and stores a document that is already at hand, like
+// the content a WebSub hub pushed.
+func (in *Ingester) IngestDocument(ctx context.Context, feed Feed, doc Document) (Result, error) {
+	parser, err := in.parser(feed)
+	if err != nil {
+		return Result{}, err
+	}
+	result, err := parser.Parse(feed, doc)
+	if err != nil {
+		return Result{}, err
+	}
+	return in.store(ctx, feed, result)
+}
+
+func (in *Ingester) parser(feed Feed) (Parser, error) {
+	parser, ok := in.parsers[feed.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown feed kind %q", feed.Kind)
+	}
+	return parser, nil
+}
+
+func (in *Ingester) store(ctx context.Context, feed Feed, result Result) (Result, error) {
+	created, err := in.sink.Store(ctx, feed, result.Items)
+	result.Created = created
+	if err != nil {
+		return result, fmt.Errorf("couldn't store items: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeFetcher map[string]Document
+
+func (f fakeFetcher) Fetch(ctx context.Context, url string) (Document, error) {
+	doc, ok := f[url]
+	if !ok {
+		return Document{}, errors.New("not found")
+	}
+	return doc, nil
+}
+
+type fakeSink struct {
+	stored map[string]bool
+	err    error
+}
+
+func (s *fakeSink) Store(ctx context.Context, feed Feed, items []Item) (int, error) {
+	created := 0
+	for _, item := range items {
+		if !s.stored[item.Link] {
+			s.stored[item.Link] = true
+			created++
+		}
+	}
+	return created, s.err
+}
+
+// lineParser reads one item per line of a plain text document.
+type lineParser struct{}
+
+func (lineParser) Parse(feed Feed, doc Document) (Result, error) {
+	result := Result{}
+	for _, line := range strings.Split(strings.TrimSpace(string(doc.Body)), "\n") {
+		result.Items = append(result.Items, Item{Title: line, Link: feed.URL + "#" + line})
+	}
+	return result, nil
+}
+
+func TestIngest(t *testing.T) {
+	fetcher := fakeFetcher{
+		"https://example.com/podcast.xml": {
+			URL:         "https://example.com/podcast.xml",
+			ContentType: "application/rss+xml",
+			Body:        []byte(podcastFeed),
+		},
+	}
+	sink := &fakeSink{stored: map[string]bool{}}
+	ingester := New(fetcher, sink)
+	feed := Feed{ID: uuid.New(), URL: "https://example.com/podcast.xml", Kind: FeedKindRSS}
+
+	result, err := ingester.Ingest(context.Background(), feed)
+	assert.NoError(t, err)
+	if assert.Len(t, result.Items, 1) {
+		item := result.Items[0]
+		assert.Equal(t, "Episode 3", item.Title)
+		assert.Equal(t, int32(3), item.Episode)
+		assert.Len(t, item.Attachments, 4)
+	}
+	assert.Equal(t, 1, result.Created)
+
+	result, err = ingester.Ingest(context.Background(), feed)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Created)
+
+	_, err = ingester.Ingest(context.Background(), Feed{URL: "https://example.com/missing.xml", Kind: FeedKindRSS})
+	assert.Error(t, err)
+	_, err = ingester.Ingest(context.Background(), Feed{URL: "https://example.com/podcast.xml", Kind: "lines"})
+	assert.ErrorContains(t, err, "unknown feed kind")
+
+	sink.err = errors.New("disk full")
+	_, err = ingester.Ingest(context.Background(), feed)
+	assert.ErrorContains(t, err, "couldn't store items: disk full")
+}
+
+func TestIngesterRegister(t *testing.T) {
+	sink := &fakeSink{stored: map[string]bool{}}
+	ingester := New(fakeFetcher{}, sink)
+	ingester.Register("lines", lineParser{})
+	feed := Feed{URL: "https://example.com/notes.txt", Kind: "lines"}
+
+	result, err := ingester.IngestDocument(context.Background(), feed, Document{Body: []byte("one\ntwo\n")})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Created)
+	assert.True(t, sink.stored["https://example.com/notes.txt#two"])
+}
+
+func TestExtractor(t *testing.T) {
+	var mu sync.Mutex
+	running, most := 0, 0
+	done := make(chan struct{})
+	release := make(chan struct{})
+	extractor := &Extractor{
+		queue:   make(chan database.Post, 10),
+		workers: make(chan struct{}, 2),
+		extract: func(post database.Post) {
+			mu.Lock()
+			running++
+			most = max(most, running)
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			done <- struct{}{}
+		},
+	}
+	// Enqueue returns right away, however slow the extraction.
+	for i := 0; i < 5; i++ {
+		extractor.Enqueue(database.Post{ID: uuid.New()})
+	}
+	close(release)
+	for i := 0; i < 5; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("post wasn't extracted")
+		}
+	}
+	assert.LessOrEqual(t, most, 2)
+}
@@ -0,0 +1,169 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ParseFeed decodes an RSS document as the XML spec says to, after
+// transcoding it to UTF-8. It rejects feeds ParseFeedLenient would repair,
+// which is what validating a feed needs.
+func ParseFeed(data []byte, contentType string) (RSSFeed, error) {
+	data, err := toUTF8(data, contentType)
+	if err != nil {
+		return RSSFeed{}, err
+	}
+	return decodeFeed(data, true)
+}
+
+// ParseFeedLenient parses like ParseFeed and, when that fails, repairs what
+// commonly breaks feeds in the wild and tries again: control characters and
+// invalid UTF-8 are dropped, stray ampersands escaped, HTML entities such as
+// &nbsp; understood and a truncated document closed after its last complete
+// item. warning says what the strict parse failed on, empty when it didn't.
+func ParseFeedLenient(data []byte, contentType string) (feed RSSFeed, warning string, err error) {
+	data, err = toUTF8(data, contentType)
+	if err != nil {
+		return RSSFeed{}, "", err
+	}
+	feed, strictErr := decodeFeed(data, true)
+	if strictErr == nil {
+		return feed, "", nil
+	}
+	feed, err = decodeFeed(closeTruncated(cleanXML(data)), false)
+	if err != nil {
+		// The first problem explains more than what the repair tripped on.
+		return RSSFeed{}, "", strictErr
+	}
+	return feed, fmt.Sprintf("parsed with recovery: %v", strictErr), nil
+}
+
+func decodeFeed(data []byte, strict bool) (RSSFeed, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// toUTF8 already transcoded, whatever the declaration says.
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if !strict {
+		decoder.Strict = false
+		decoder.AutoClose = xml.HTMLAutoClose
+		decoder.Entity = xml.HTMLEntity
+	}
+	rssFeed := RSSFeed{}
+	err := decoder.Decode(&rssFeed)
+	if err != nil {
+		return RSSFeed{}, fmt.Errorf("couldn't parse feed: %w", err)
+	}
+	return rssFeed, nil
+}
+
+// entityRef matches what may follow an ampersand that starts a reference.
+var entityRef = regexp.MustCompile(`^(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9._-]*);`)
+
+// cleanXML drops characters XML doesn't allow and escapes ampersands that
+// don't start a reference, leaving CDATA sections and comments as they are.
+func cleanXML(data []byte) []byte {
+	s := strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(string(data), ""))
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		if section := literalSection(s[i:]); section != "" {
+			b.WriteString(section)
+			i += len(section)
+			continue
+		}
+		if s[i] == '&' && !entityRef.MatchString(s[i+1:]) {
+			b.WriteString("&amp;")
+		} else {
+			b.WriteByte(s[i])
+		}
+		i++
+	}
+	return []byte(b.String())
+}
+
+// literalSection returns the CDATA section or comment s starts with, up to
+// the end of s if it isn't closed.
+func literalSection(s string) string {
+	for _, delims := range [][2]string{{"<![CDATA[", "]]>"}, {"<!--", "-->"}} {
+		if !strings.HasPrefix(s, delims[0]) {
+			continue
+		}
+		end := strings.Index(s[len(delims[0]):], delims[1])
+		if end < 0 {
+			return s
+		}
+		return s[:len(delims[0])+end+len(delims[1])]
+	}
+	return ""
+}
+
+// closeTruncated cuts a document that ends early after its last complete
+// item and closes the elements left open, keeping the items before the cut.
+func closeTruncated(data []byte) []byte {
+	type openElement struct {
+		name  string
+		start int
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	var open []openElement
+	end := 0
+	for {
+		start := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				data = data[:end]
+			}
+			break
+		}
+		end = int(decoder.InputOffset())
+		switch t := token.(type) {
+		case xml.StartElement:
+			open = append(open, openElement{name: qualifiedName(t.Name), start: start})
+		case xml.EndElement:
+			// Elements HTML leaves open, like <br>, close with their parent.
+			name := qualifiedName(t.Name)
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i].name == name {
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+	if len(open) == 0 {
+		return data
+	}
+	// Half an item would be stored as a post with parts missing.
+	for i, element := range open {
+		if element.name == "item" {
+			data = data[:element.start]
+			open = open[:i]
+			break
+		}
+	}
+	repaired := append([]byte{}, data...)
+	for i := len(open) - 1; i >= 0; i-- {
+		repaired = append(repaired, "</"+open[i].name+">"...)
+	}
+	return repaired
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
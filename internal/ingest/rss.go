@@ -0,0 +1,272 @@
+package ingest
+
+import (
+	"encoding/xml"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RSSFeed struct {
+	// XMLName makes documents other than RSS an error rather than an empty
+	// feed.
+	XMLName xml.Name `xml:"rss"`
+	Channel struct {
+		Title string `xml:"title"`
+		// AtomLinks comes before Link, which would take atom:link
+		// elements too.
+		AtomLinks   []RSSAtomLink `xml:"http://www.w3.org/2005/Atom link"`
+		Link        string        `xml:"link"`
+		Description string        `xml:"description"`
+		Language    string        `xml:"language"`
+		Item        []RSSItem     `xml:"item"`
+	} `xml:"channel"`
+}
+
+// RSSAtomLink is an atom:link of a channel, which is how feeds point to
+// themselves ("self") and their WebSub hub ("hub").
+type RSSAtomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// Hub is the WebSub hub pushing updates of the feed, empty if it has none.
+func (feed RSSFeed) Hub() string {
+	return feed.atomLink("hub")
+}
+
+// Self is the canonical URL of the feed, the topic to subscribe to at its
+// hub. Empty if it doesn't say.
+func (feed RSSFeed) Self() string {
+	return feed.atomLink("self")
+}
+
+func (feed RSSFeed) atomLink(rel string) string {
+	for _, link := range feed.Channel.AtomLinks {
+		if strings.EqualFold(link.Rel, rel) && link.Href != "" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+type RSSItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+	// Content is the full text, when Description is only a teaser.
+	Content    string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Creators   []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Author     string   `xml:"author"`
+	Categories []string `xml:"category"`
+
+	Enclosures     []RSSEnclosure `xml:"enclosure"`
+	ITunesDuration string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	ITunesImage    struct {
+		Href string `xml:"href,attr"`
+	} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	ITunesEpisode  string              `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	ITunesSeason   string              `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	MediaContent   []RSSMediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnail []RSSMediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaGroup     struct {
+		Content   []RSSMediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+		Thumbnail []RSSMediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	} `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type RSSMediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Duration string `xml:"duration,attr"`
+	Width    string `xml:"width,attr"`
+	Height   string `xml:"height,attr"`
+}
+
+type RSSMediaThumbnail struct {
+	URL    string `xml:"url,attr"`
+	Width  string `xml:"width,attr"`
+	Height string `xml:"height,attr"`
+}
+
+// Attachments gathers the enclosures, Media RSS files and images of item. The
+// iTunes duration goes to the first enclosure, the episode it describes.
+func (item RSSItem) Attachments() []Attachment {
+	attachments := []Attachment{}
+	for i, enclosure := range item.Enclosures {
+		if enclosure.URL == "" {
+			continue
+		}
+		attachment := Attachment{
+			Kind:     "enclosure",
+			URL:      enclosure.URL,
+			MimeType: enclosure.Type,
+			Length:   parseInt(enclosure.Length, 64),
+		}
+		if i == 0 {
+			attachment.Duration = parseDuration(item.ITunesDuration)
+		}
+		attachments = append(attachments, attachment)
+	}
+	for _, content := range append(item.MediaContent, item.MediaGroup.Content...) {
+		if content.URL == "" {
+			continue
+		}
+		attachments = append(attachments, Attachment{
+			Kind:     "media",
+			URL:      content.URL,
+			MimeType: content.Type,
+			Length:   parseInt(content.FileSize, 64),
+			Duration: parseDuration(content.Duration),
+			Width:    int32(parseInt(content.Width, 32)),
+			Height:   int32(parseInt(content.Height, 32)),
+		})
+	}
+	for _, thumbnail := range append(item.MediaThumbnail, item.MediaGroup.Thumbnail...) {
+		if thumbnail.URL == "" {
+			continue
+		}
+		attachments = append(attachments, Attachment{
+			Kind:   "thumbnail",
+			URL:    thumbnail.URL,
+			Width:  int32(parseInt(thumbnail.Width, 32)),
+			Height: int32(parseInt(thumbnail.Height, 32)),
+		})
+	}
+	if item.ITunesImage.Href != "" {
+		attachments = append(attachments, Attachment{Kind: "image", URL: item.ITunesImage.Href})
+	}
+	return attachments
+}
+
+// AuthorNames are the Dublin Core creators of item, or else its author with
+// the email address RSS asks for left out: "jane@example.com (Jane)" is Jane.
+func (item RSSItem) AuthorNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	authors := item.Creators
+	if len(authors) == 0 && item.Author != "" {
+		authors = []string{item.Author}
+	}
+	for _, author := range authors {
+		author = strings.TrimSpace(author)
+		if open := strings.Index(author, "("); open >= 0 && strings.HasSuffix(author, ")") {
+			author = strings.TrimSpace(author[open+1 : len(author)-1])
+		}
+		if author == "" || seen[strings.ToLower(author)] {
+			continue
+		}
+		seen[strings.ToLower(author)] = true
+		names = append(names, author)
+	}
+	return names
+}
+
+// CategoryNames are the categories of item, without blanks or duplicates.
+func (item RSSItem) CategoryNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, category := range item.Categories {
+		category = strings.TrimSpace(category)
+		if category == "" || seen[strings.ToLower(category)] {
+			continue
+		}
+		seen[strings.ToLower(category)] = true
+		names = append(names, category)
+	}
+	return names
+}
+
+// Episode is the iTunes episode number of item, 0 if it has none.
+func (item RSSItem) Episode() int32 {
+	return int32(parseInt(item.ITunesEpisode, 32))
+}
+
+// Season is the iTunes season number of item, 0 if it has none.
+func (item RSSItem) Season() int32 {
+	return int32(parseInt(item.ITunesSeason, 32))
+}
+
+// parseDuration reads a duration in seconds, as "3600", "60:00" or
+// "1:00:00". Fractions of seconds are dropped and anything else is 0.
+func parseDuration(value string) int32 {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	value, _, _ = strings.Cut(value, ".")
+	var seconds int64
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseInt(part, 10, 32)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	if seconds > math.MaxInt32 {
+		return 0
+	}
+	return int32(seconds)
+}
+
+// parseInt reads a non-negative integer attribute fitting in bitSize bits, 0
+// when missing or malformed.
+func parseInt(value string, bitSize int) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, bitSize)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Item normalizes item. A pubDate that isn't RFC 1123 is logged and left
+// as the zero time.
+func (item RSSItem) Item() Item {
+	published, err := time.Parse(time.RFC1123Z, item.PubDate)
+	if err != nil {
+		log.Printf("Couldn't parse date %v, err: %v", item.PubDate, err)
+	}
+	return Item{
+		Title:       item.Title,
+		Link:        item.Link,
+		Description: item.Description,
+		Content:     item.Content,
+		PublishedAt: published,
+		Authors:     item.AuthorNames(),
+		Categories:  item.CategoryNames(),
+		Episode:     item.Episode(),
+		Season:      item.Season(),
+		Attachments: item.Attachments(),
+	}
+}
+
+// RSSParser reads RSS feeds, repairing malformed ones.
+type RSSParser struct{}
+
+func (RSSParser) Parse(feed Feed, doc Document) (Result, error) {
+	rssFeed, warning, err := ParseFeedLenient(doc.Body, doc.ContentType)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Warning: warning, Hub: rssFeed.Hub(), Items: []Item{}}
+	if result.Hub != "" {
+		result.Topic = rssFeed.Self()
+		if result.Topic == "" {
+			result.Topic = feed.URL
+		}
+	}
+	for _, item := range rssFeed.Channel.Item {
+		result.Items = append(result.Items, item.Item())
+	}
+	return result, nil
+}
@@ -0,0 +1,190 @@
+package ingest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const podcastFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:media="http://search.yahoo.com/mrss/">
+<channel>
+	<title>Podcast</title>
+	<item>
+		<title>Episode 3</title>
+		<link>https://example.com/3</link>
+		<enclosure url="https://example.com/3.mp3" type="audio/mpeg" length="1234"/>
+		<itunes:duration>1:02:03</itunes:duration>
+		<itunes:image href="https://example.com/3.jpg"/>
+		<itunes:episode>3</itunes:episode>
+		<itunes:season>2</itunes:season>
+		<media:group>
+			<media:content url="https://example.com/3.mp4" type="video/mp4" duration="60" width="640" height="360"/>
+		</media:group>
+		<media:thumbnail url="https://example.com/3-thumb.jpg" width="120" height="90"/>
+	</item>
+</channel>
+</rss>`
+
+func TestRSSItemAttachments(t *testing.T) {
+	feed := RSSFeed{}
+	err := xml.Unmarshal([]byte(podcastFeed), &feed)
+	assert.NoError(t, err)
+	item := feed.Channel.Item[0]
+	assert.Equal(t, int32(3), item.Episode())
+	assert.Equal(t, int32(2), item.Season())
+	assert.Equal(t, []Attachment{
+		{Kind: "enclosure", URL: "https://example.com/3.mp3", MimeType: "audio/mpeg", Length: 1234, Duration: 3723},
+		{Kind: "media", URL: "https://example.com/3.mp4", MimeType: "video/mp4", Duration: 60, Width: 640, Height: 360},
+		{Kind: "thumbnail", URL: "https://example.com/3-thumb.jpg", Width: 120, Height: 90},
+		{Kind: "image", URL: "https://example.com/3.jpg"},
+	}, item.Attachments())
+}
+
+func TestParseDuration(t *testing.T) {
+	assert.Equal(t, int32(3600), parseDuration("3600"))
+	assert.Equal(t, int32(3600), parseDuration("60:00"))
+	assert.Equal(t, int32(3661), parseDuration(" 1:01:01.5 "))
+	assert.Equal(t, int32(0), parseDuration("an hour"))
+	assert.Equal(t, int32(0), parseDuration(""))
+}
+
+func TestRSSItemAuthorsAndCategories(t *testing.T) {
+	feed := RSSFeed{}
+	err := xml.Unmarshal([]byte(`<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<item>
+		<title>Long read</title>
+		<description>Teaser</description>
+		<content:encoded><![CDATA[<p>The whole story</p>]]></content:encoded>
+		<dc:creator>Ada</dc:creator>
+		<dc:creator>Grace</dc:creator>
+		<dc:creator>ada</dc:creator>
+		<category>Go</category>
+		<category> go </category>
+		<category>Databases</category>
+	</item>
+	<item>
+		<author>jane@example.com (Jane Doe)</author>
+		<category></category>
+	</item>
+</channel>
+</rss>`), &feed)
+	assert.NoError(t, err)
+	item := feed.Channel.Item[0]
+	assert.Equal(t, "Teaser", item.Description)
+	assert.Equal(t, "<p>The whole story</p>", item.Content)
+	assert.Equal(t, []string{"Ada", "Grace"}, item.AuthorNames())
+	assert.Equal(t, []string{"Go", "Databases"}, item.CategoryNames())
+
+	item = feed.Channel.Item[1]
+	assert.Equal(t, []string{"Jane Doe"}, item.AuthorNames())
+	assert.Equal(t, []string{}, item.CategoryNames())
+}
+
+func encodeFeed(t *testing.T, encoder *encoding.Encoder, declared, title string) []byte {
+	t.Helper()
+	prolog := ""
+	if declared != "" {
+		prolog = fmt.Sprintf(`<?xml version="1.0" encoding="%s"?>`, declared)
+	}
+	data, err := encoder.Bytes([]byte(prolog + "<rss><channel><title>" + title + "</title></channel></rss>"))
+	assert.NoError(t, err)
+	return data
+}
+
+func TestParseFeedCharsets(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		title       string
+	}{
+		{"latin-1 declared", encodeFeed(t, charmap.ISO8859_1.NewEncoder(), "ISO-8859-1", "Café"), "", "Café"},
+		{"windows-1252 from header", encodeFeed(t, charmap.Windows1252.NewEncoder(), "", "“Quoted” café"), "text/xml; charset=windows-1252", "“Quoted” café"},
+		{"header wins over declaration", encodeFeed(t, charmap.Windows1252.NewEncoder(), "UTF-8", "Café"), "application/rss+xml; charset=Windows-1252", "Café"},
+		{"wrong utf-8 header", encodeFeed(t, charmap.ISO8859_1.NewEncoder(), "ISO-8859-1", "Café"), "text/xml; charset=utf-8", "Café"},
+		{"shift_jis", encodeFeed(t, japanese.ShiftJIS.NewEncoder(), "Shift_JIS", "日本語のニュース"), "", "日本語のニュース"},
+		{"gb2312", encodeFeed(t, simplifiedchinese.GBK.NewEncoder(), "GB2312", "中文新闻"), "", "中文新闻"},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, encodeFeed(t, encoding.Nop.NewEncoder(), "UTF-8", "Café")...), "", "Café"},
+		{"utf-16 bom", encodeFeed(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder(), "UTF-16", "Café"), "", "Café"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			feed, err := ParseFeed(test.data, test.contentType)
+			assert.NoError(t, err)
+			assert.Equal(t, test.title, feed.Channel.Title)
+		})
+	}
+}
+
+func TestParseFeedErrors(t *testing.T) {
+	_, err := ParseFeed([]byte(`<rss><channel><title>Cut off`), "")
+	assert.Error(t, err)
+	_, err = ParseFeed([]byte(`<html><body>Not found</body></html>`), "text/html")
+	assert.Error(t, err)
+	_, err = ParseFeed([]byte(`<?xml version="1.0" encoding="x-unknown"?><rss></rss>`), "")
+	assert.Error(t, err)
+	_, err = ParseFeed([]byte(`<rss><channel><title>Fine</title></channel></rss>`), "text/xml; charset=x-unknown")
+	assert.Error(t, err)
+}
+
+func TestParseFeedLenient(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		titles []string
+	}{
+		{"stray ampersand", `<rss><channel><item><title>Salt & Pepper</title></item></channel></rss>`, []string{"Salt & Pepper"}},
+		{"html entity", `<rss><channel><item><title>Caf&eacute;&nbsp;&mdash; open</title></item></channel></rss>`, []string{"Café — open"}},
+		{"control characters", "<rss><channel><item><title>Bell\x07 and\x00 null</title></item></channel></rss>", []string{"Bell and null"}},
+		{"truncated", `<rss><channel><item><title>First</title></item><item><title>Second</title></item><item><title>Thi`, []string{"First", "Second"}},
+		{"cdata kept", `<rss><channel><item><title>A & B</title><description><![CDATA[Q&A &amp; more]]></description></item></channel></rss>`, []string{"A & B"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseFeed([]byte(test.data), "")
+			assert.Error(t, err)
+			feed, warning, err := ParseFeedLenient([]byte(test.data), "")
+			assert.NoError(t, err)
+			assert.Contains(t, warning, "parsed with recovery")
+			titles := []string{}
+			for _, item := range feed.Channel.Item {
+				titles = append(titles, item.Title)
+			}
+			assert.Equal(t, test.titles, titles)
+		})
+	}
+
+	feed, warning, err := ParseFeedLenient([]byte(`<rss><channel><item><title>A &amp; B</title><description><![CDATA[Q&A &amp; more]]></description></item></channel></rss>`), "")
+	assert.NoError(t, err)
+	assert.Empty(t, warning)
+	assert.Equal(t, "Q&A &amp; more", feed.Channel.Item[0].Description)
+
+	_, _, err = ParseFeedLenient([]byte(`<html><body>Not found</body></html>`), "text/html")
+	assert.Error(t, err)
+}
+
+func TestRSSFeedHub(t *testing.T) {
+	feed, err := ParseFeed([]byte(`<rss xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+		<title>Pushed</title>
+		<atom:link rel="hub" href="https://hub.example.com/"/>
+		<atom:link rel="self" href="https://example.com/feed.xml"/>
+		<link>https://example.com/</link>
+	</channel></rss>`), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://hub.example.com/", feed.Hub())
+	assert.Equal(t, "https://example.com/feed.xml", feed.Self())
+	assert.Equal(t, "https://example.com/", feed.Channel.Link)
+
+	feed, err = ParseFeed([]byte(`<rss><channel><link>https://example.com/</link></channel></rss>`), "")
+	assert.NoError(t, err)
+	assert.Empty(t, feed.Hub())
+}
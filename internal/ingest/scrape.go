@@ -0,0 +1,211 @@
+package ingest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html/charset"
+)
+
+// ScrapeConfig says where the posts are on a page without a feed, as CSS
+// selectors. Item matches each post, the others are relative to it.
+type ScrapeConfig struct {
+	Item string `json:"item"`
+	// Title defaults to the text of the whole item.
+	Title string `json:"title"`
+	// Link defaults to the first link in the item, or the item itself.
+	Link string `json:"link"`
+	// Date is read from a datetime attribute if there is one, else from the
+	// text, in DateFormat (a Go time layout) or a common format. Posts
+	// without one are dated when first seen.
+	Date       string `json:"date"`
+	DateFormat string `json:"date_format"`
+	Summary    string `json:"summary"`
+}
+
+// maxScrapedItems keeps a selector matching every element from flooding
+// the feed.
+const maxScrapedItems = 100
+
+// scrapedDateLayouts are tried for dates when DateFormat isn't set.
+var scrapedDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"02 Jan 2006",
+	"01/02/2006",
+}
+
+func ParseScrapeConfig(raw json.RawMessage) (ScrapeConfig, error) {
+	config := ScrapeConfig{}
+	err := json.Unmarshal(raw, &config)
+	if err != nil {
+		return ScrapeConfig{}, err
+	}
+	return config, config.Validate()
+}
+
+// Validate checks that Item is set and every selector is valid CSS.
+func (config ScrapeConfig) Validate() error {
+	if strings.TrimSpace(config.Item) == "" {
+		return errors.New("an item selector is required")
+	}
+	selectors := []struct{ name, value string }{
+		{"item", config.Item},
+		{"title", config.Title},
+		{"link", config.Link},
+		{"date", config.Date},
+		{"summary", config.Summary},
+	}
+	for _, selector := range selectors {
+		if selector.value == "" {
+			continue
+		}
+		_, err := cascadia.ParseGroup(selector.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s selector: %v", selector.name, err)
+		}
+	}
+	return nil
+}
+
+// ScrapeParser reads the posts of scraped feeds off their page.
+type ScrapeParser struct{}
+
+func (ScrapeParser) Parse(feed Feed, doc Document) (Result, error) {
+	config, err := ParseScrapeConfig(feed.ScrapeConfig)
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid scrape config: %w", err)
+	}
+	pageURL := doc.URL
+	if pageURL == "" {
+		pageURL = feed.URL
+	}
+	items, err := ScrapePage(doc.Body, doc.ContentType, pageURL, config)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Items: items}, nil
+}
+
+// ScrapePage finds the posts in an HTML page served from pageURL.
+func ScrapePage(data []byte, contentType, pageURL string, config ScrapeConfig) ([]Item, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := charset.NewReader(bytes.NewReader(data), contentType)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse page: %w", err)
+	}
+	now := time.Now().UTC()
+	items := []Item{}
+	doc.Find(config.Item).EachWithBreak(func(_ int, selection *goquery.Selection) bool {
+		item := Item{
+			Title: collapseSpace(findIn(selection, config.Title).Text()),
+			Link:  scrapedLink(selection, config.Link, base),
+		}
+		if config.Summary != "" {
+			item.Description, _ = selection.Find(config.Summary).First().Html()
+		}
+		if item.Title == "" && item.Link == "" {
+			return true
+		}
+		if item.Link == "" {
+			// Posts are told apart by their link, a page without them
+			// gets one per title.
+			sum := sha256.Sum256([]byte(item.Title))
+			fragment := *base
+			fragment.Fragment = hex.EncodeToString(sum[:8])
+			item.Link = fragment.String()
+		}
+		item.PublishedAt = now
+		if config.Date != "" {
+			if date, ok := scrapedDate(selection.Find(config.Date).First(), config.DateFormat); ok {
+				item.PublishedAt = date
+			}
+		}
+		items = append(items, item)
+		return len(items) < maxScrapedItems
+	})
+	return items, nil
+}
+
+// findIn returns what selector matches in selection, or selection itself
+// when there is no selector.
+func findIn(selection *goquery.Selection, selector string) *goquery.Selection {
+	if selector == "" {
+		return selection
+	}
+	return selection.Find(selector).First()
+}
+
+func scrapedLink(selection *goquery.Selection, selector string, base *url.URL) string {
+	var link *goquery.Selection
+	switch {
+	case selector != "":
+		link = selection.Find(selector).First()
+	case selection.Is("a[href]"):
+		link = selection
+	default:
+		link = selection.Find("a[href]").First()
+	}
+	href, ok := link.Attr("href")
+	if !ok || strings.TrimSpace(href) == "" {
+		return ""
+	}
+	resolved, err := base.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	return resolved.String()
+}
+
+func scrapedDate(selection *goquery.Selection, layout string) (time.Time, bool) {
+	value, ok := selection.Attr("datetime")
+	if !ok {
+		value = selection.Text()
+	}
+	value = collapseSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	layouts := scrapedDateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	for _, layout := range layouts {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
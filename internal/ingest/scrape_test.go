@@ -0,0 +1,55 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const changelogPage = `<html><body>
+<ul class="releases">
+	<li class="release">
+		<h2><a href="/changelog/2.1">Version 2.1</a></h2>
+		<time datetime="2024-03-01T10:00:00Z">March 1</time>
+		<div class="notes"><p>Faster <b>sync</b></p></div>
+	</li>
+	<li class="release">
+		<h2>Version 2.0</h2>
+		<span class="date">February 2, 2024</span>
+	</li>
+	<li class="release"></li>
+</ul>
+</body></html>`
+
+func TestScrapePage(t *testing.T) {
+	items, err := ScrapePage([]byte(changelogPage), "text/html; charset=utf-8", "https://example.com/changelog", ScrapeConfig{
+		Item:    "li.release",
+		Title:   "h2",
+		Date:    "time, .date",
+		Summary: ".notes",
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "Version 2.1", items[0].Title)
+		assert.Equal(t, "https://example.com/changelog/2.1", items[0].Link)
+		assert.Equal(t, "<p>Faster <b>sync</b></p>", items[0].Description)
+		assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), items[0].PublishedAt)
+
+		assert.Equal(t, "Version 2.0", items[1].Title)
+		assert.Contains(t, items[1].Link, "https://example.com/changelog#")
+		assert.Equal(t, time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC), items[1].PublishedAt)
+	}
+}
+
+func TestScrapeConfigValidate(t *testing.T) {
+	assert.NoError(t, ScrapeConfig{Item: "article", Title: "h1, h2"}.Validate())
+	assert.Error(t, ScrapeConfig{Title: "h1"}.Validate())
+	assert.Error(t, ScrapeConfig{Item: "article", Link: "a[href"}.Validate())
+
+	_, err := ParseScrapeConfig([]byte(`{"item": "li", "date": ">>"}`))
+	assert.Error(t, err)
+	config, err := ParseScrapeConfig([]byte(`{"item": "li", "date_format": "02.01.2006"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, ScrapeConfig{Item: "li", DateFormat: "02.01.2006"}, config)
+}
@@ -0,0 +1,120 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/sanitize"
+)
+
+// DBSink stores items as posts, skipping those already stored.
+type DBSink struct {
+	DB *database.Queries
+	// Extractor gets the articles of feeds followed with full content, none
+	// are when it's nil.
+	Extractor *Extractor
+}
+
+// Store saves every item it can, returning the last error when some
+// couldn't be.
+func (sink DBSink) Store(ctx context.Context, feed Feed, items []Item) (int, error) {
+	db := sink.DB
+	wantsFullContent := false
+	if sink.Extractor != nil {
+		var err error
+		wantsFullContent, err = db.FeedWantsFullContent(ctx, feed.ID)
+		if err != nil {
+			log.Println("Couldn't check full content setting: ", err)
+		}
+	}
+	created, failed := 0, 0
+	var lastErr error
+	for _, item := range items {
+		// Relative links in the markup are relative to the item page.
+		base := item.Link
+		if base == "" {
+			base = feed.URL
+		}
+		description := sanitize.HTML(item.Description, base)
+		content := sanitize.HTML(item.Content, base)
+		desc := sql.NullString{}
+		if description != "" {
+			desc = sql.NullString{
+				Valid:  true,
+				String: description,
+			}
+		}
+		text := description
+		if text == "" {
+			text = content
+		}
+		authors := item.Authors
+		if authors == nil {
+			authors = []string{}
+		}
+		post, err := db.CreatePost(ctx, database.CreatePostParams{
+			ID:              uuid.New(),
+			CreatedAt:       time.Now().UTC(),
+			UpdatedAt:       time.Now().UTC(),
+			Title:           item.Title,
+			Description:     desc,
+			PublishedAt:     item.PublishedAt,
+			Url:             item.Link,
+			FeedID:          feed.ID,
+			Episode:         sql.NullInt32{Int32: item.Episode, Valid: item.Episode > 0},
+			Season:          sql.NullInt32{Int32: item.Season, Valid: item.Season > 0},
+			Content:         sql.NullString{String: content, Valid: content != ""},
+			Authors:         authors,
+			DescriptionText: sql.NullString{String: sanitize.Text(text), Valid: true},
+		})
+		if err != nil {
+			if strings.Contains(err.Error(), "duplicate key") {
+				continue
+			}
+			log.Println("Couldn't create post: ", err)
+			failed++
+			lastErr = err
+			continue
+		}
+		created++
+		for _, attachment := range item.Attachments {
+			err = db.CreatePostAttachment(ctx, database.CreatePostAttachmentParams{
+				ID:              uuid.New(),
+				CreatedAt:       time.Now().UTC(),
+				PostID:          post.ID,
+				Kind:            attachment.Kind,
+				Url:             attachment.URL,
+				MimeType:        sql.NullString{String: attachment.MimeType, Valid: attachment.MimeType != ""},
+				Length:          sql.NullInt64{Int64: attachment.Length, Valid: attachment.Length > 0},
+				DurationSeconds: sql.NullInt32{Int32: attachment.Duration, Valid: attachment.Duration > 0},
+				Width:           sql.NullInt32{Int32: attachment.Width, Valid: attachment.Width > 0},
+				Height:          sql.NullInt32{Int32: attachment.Height, Valid: attachment.Height > 0},
+			})
+			if err != nil {
+				log.Println("Couldn't create post attachment: ", err)
+			}
+		}
+		for _, category := range item.Categories {
+			err = db.CreatePostCategory(ctx, database.CreatePostCategoryParams{
+				PostID: post.ID,
+				Name:   category,
+			})
+			if err != nil {
+				log.Println("Couldn't create post category: ", err)
+			}
+		}
+		if wantsFullContent && item.Link != "" {
+			sink.Extractor.Enqueue(post)
+		}
+	}
+	if lastErr != nil {
+		return created, fmt.Errorf("couldn't store %d of %d items: %w", failed, len(items), lastErr)
+	}
+	return created, nil
+}
@@ -0,0 +1,298 @@
+// Package postfilter queries the posts a user follows by any combination of
+// filters and sorts. The ORDER BY and keyset condition depend on the
+// requested sort, which sqlc's static queries can't express, so the SQL is
+// built here.
+package postfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/lib/pq"
+)
+
+// searchVector is the text matched by Params.Query.
+const searchVector = "to_tsvector('english', posts.title || ' ' || COALESCE(posts.description, ''))"
+
+type sortColumn struct {
+	// expr is the SQL expression the column sorts on, %[1]d stands for the
+	// parameter holding the full text search query.
+	expr string
+	// key converts a cursor key of the column, as decoded from JSON, to the
+	// value compared with expr, reporting whether it has the right type.
+	key func(value interface{}) (interface{}, bool)
+}
+
+var sortColumns = map[string]sortColumn{
+	"published_at": {"posts.published_at", timeKey},
+	"created_at":   {"posts.created_at", timeKey},
+	"feed_name":    {"feeds.name", textKey},
+	"title":        {"posts.title", textKey},
+	"description":  {"COALESCE(posts.description, '')", textKey},
+	"relevance":    {"ts_rank(" + searchVector + ", plainto_tsquery('english', $%[1]d))", numberKey},
+}
+
+// Timestamps are scanned as time.Time and encoded in JSON as RFC 3339.
+func timeKey(value interface{}) (interface{}, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
+
+func textKey(value interface{}) (interface{}, bool) {
+	s, ok := value.(string)
+	return s, ok
+}
+
+func numberKey(value interface{}) (interface{}, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+// IsSortColumn reports whether posts can be sorted by column.
+func IsSortColumn(column string) bool {
+	_, ok := sortColumns[column]
+	return ok
+}
+
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+var (
+	ErrCursorMismatch  = errors.New("cursor doesn't match sort")
+	ErrMalformedCursor = errors.New("malformed cursor")
+)
+
+// Cursor positions a keyset page right after (or, when Backward, right
+// before) the post holding the given sort key values and id. Sort records the
+// sort the keys were taken from.
+type Cursor struct {
+	Sort     string        `json:"s,omitempty"`
+	Keys     []interface{} `json:"k"`
+	ID       uuid.UUID     `json:"id"`
+	Backward bool          `json:"b,omitempty"`
+}
+
+// Values returns the keys of the cursor as the values bound to the query
+// sorted by sort. Keys come from clients, so each must have the type of its
+// column.
+func (c *Cursor) Values(sort []Sort) ([]interface{}, error) {
+	if len(c.Keys) != len(sort) {
+		return nil, ErrCursorMismatch
+	}
+	values := make([]interface{}, len(sort))
+	for i, s := range sort {
+		column, ok := sortColumns[s.Column]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort column %q", s.Column)
+		}
+		values[i], ok = column.key(c.Keys[i])
+		if !ok {
+			return nil, ErrMalformedCursor
+		}
+	}
+	return values, nil
+}
+
+// Params filters posts by the fields set. Author matches part of any author
+// name and Category a whole category, both ignoring case.
+type Params struct {
+	UserID      uuid.UUID
+	Title       string
+	Description string
+	Author      string
+	Category    string
+	Query       string
+	Before      time.Time
+	After       time.Time
+	Sort        []Sort
+	Cursor      *Cursor
+	Limit       int32
+}
+
+type Row struct {
+	database.Post
+	SortKeys []interface{}
+}
+
+// Posts returns a page of the posts matching arg, in the order of its sort.
+func Posts(ctx context.Context, db database.DBTX, arg Params) ([]Row, error) {
+	if len(arg.Sort) == 0 {
+		return nil, fmt.Errorf("no sort given")
+	}
+	where, args, queryArg := filterWhere(arg)
+	backward := arg.Cursor != nil && arg.Cursor.Backward
+
+	exprs := make([]string, 0, len(arg.Sort))
+	orderBy := make([]string, 0, len(arg.Sort)+1)
+	for _, sort := range arg.Sort {
+		column, ok := sortColumns[sort.Column]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort column %q", sort.Column)
+		}
+		expr := column.expr
+		if sort.Column == "relevance" {
+			if queryArg == 0 {
+				return nil, fmt.Errorf("sorting by relevance requires a search query")
+			}
+			expr = fmt.Sprintf(expr, queryArg)
+		}
+		exprs = append(exprs, expr)
+		orderBy = append(orderBy, expr+sortDirection(sort.Desc != backward))
+	}
+	lastDesc := arg.Sort[len(arg.Sort)-1].Desc
+	orderBy = append(orderBy, "posts.id"+sortDirection(lastDesc != backward))
+
+	if arg.Cursor != nil {
+		cursorValues, err := arg.Cursor.Values(arg.Sort)
+		if err != nil {
+			return nil, err
+		}
+		// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND id > vid)
+		keys := append(append([]string{}, exprs...), "posts.id")
+		values := append(cursorValues, arg.Cursor.ID)
+		var alternatives []string
+		for i := range keys {
+			desc := lastDesc
+			if i < len(arg.Sort) {
+				desc = arg.Sort[i].Desc
+			}
+			operator := ">"
+			if desc != backward {
+				operator = "<"
+			}
+			var terms []string
+			for j := 0; j < i; j++ {
+				args = append(args, values[j])
+				terms = append(terms, fmt.Sprintf("%s = $%d", keys[j], len(args)))
+			}
+			args = append(args, values[i])
+			terms = append(terms, fmt.Sprintf("%s %s $%d", keys[i], operator, len(args)))
+			alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		}
+		where = append(where, "("+strings.Join(alternatives, " OR ")+")")
+	}
+
+	args = append(args, arg.Limit)
+	query := fmt.Sprintf(`SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.description, posts.published_at, posts.url, posts.feed_id, posts.short_id, posts.episode, posts.season, posts.content, posts.authors, posts.description_text, posts.extracted_content, posts.extracted_at, %s FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE %s
+ORDER BY %s
+LIMIT $%d`, strings.Join(exprs, ", "), strings.Join(where, "\nAND "), strings.Join(orderBy, ", "), len(args))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Row
+	for rows.Next() {
+		i := Row{SortKeys: make([]interface{}, len(exprs))}
+		dest := []interface{}{
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Description,
+			&i.PublishedAt,
+			&i.Url,
+			&i.FeedID,
+			&i.ShortID,
+			&i.Episode,
+			&i.Season,
+			&i.Content,
+			pq.Array(&i.Authors),
+			&i.DescriptionText,
+			&i.ExtractedContent,
+			&i.ExtractedAt,
+		}
+		for k := range i.SortKeys {
+			dest = append(dest, &i.SortKeys[k])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	return items, nil
+}
+
+// Count counts every post matching the filters of arg, ignoring its cursor
+// and limit.
+func Count(ctx context.Context, db database.DBTX, arg Params) (int64, error) {
+	where, args, _ := filterWhere(arg)
+	query := `SELECT COUNT(*) FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE ` + strings.Join(where, "\nAND ")
+	row := db.QueryRowContext(ctx, query, args...)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+// filterWhere returns the conditions matching the filters of arg, their
+// arguments and the position of the search query argument, if any.
+func filterWhere(arg Params) ([]string, []interface{}, int) {
+	where := []string{"feed_follows.user_id = $1"}
+	args := []interface{}{arg.UserID}
+	queryArg := 0
+	if arg.Query != "" {
+		args = append(args, arg.Query)
+		queryArg = len(args)
+		where = append(where, fmt.Sprintf("%s @@ plainto_tsquery('english', $%d)", searchVector, queryArg))
+	}
+	if arg.Title != "" {
+		args = append(args, arg.Title)
+		where = append(where, fmt.Sprintf("posts.title ILIKE '%%' || $%d || '%%'", len(args)))
+	}
+	if arg.Description != "" {
+		args = append(args, arg.Description)
+		where = append(where, fmt.Sprintf("posts.description ILIKE '%%' || $%d || '%%'", len(args)))
+	}
+	if arg.Author != "" {
+		args = append(args, arg.Author)
+		where = append(where, fmt.Sprintf("EXISTS (SELECT 1 FROM unnest(posts.authors) AS author WHERE author ILIKE '%%' || $%d || '%%')", len(args)))
+	}
+	if arg.Category != "" {
+		args = append(args, arg.Category)
+		where = append(where, fmt.Sprintf("EXISTS (SELECT 1 FROM post_categories WHERE post_categories.post_id = posts.id AND lower(post_categories.name) = lower($%d))", len(args)))
+	}
+	if !arg.Before.IsZero() {
+		args = append(args, arg.Before)
+		where = append(where, fmt.Sprintf("posts.published_at <= $%d", len(args)))
+	}
+	if !arg.After.IsZero() {
+		args = append(args, arg.After)
+		where = append(where, fmt.Sprintf("posts.published_at >= $%d", len(args)))
+	}
+	return where, args, queryArg
+}
+
+func sortDirection(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+	return " ASC"
+}
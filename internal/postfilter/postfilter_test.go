@@ -0,0 +1,35 @@
+package postfilter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorValues(t *testing.T) {
+	sort := []Sort{{Column: "published_at", Desc: true}, {Column: "title"}, {Column: "relevance"}}
+	publishedAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	// Keys survive the JSON round trip of a cursor handed to a client.
+	data, err := json.Marshal(Cursor{Keys: []interface{}{publishedAt, "Title", 0.5}})
+	assert.NoError(t, err)
+	cursor := Cursor{}
+	assert.NoError(t, json.Unmarshal(data, &cursor))
+	values, err := cursor.Values(sort)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{publishedAt, "Title", 0.5}, values)
+
+	for _, keys := range [][]interface{}{
+		{"yesterday", "Title", 0.5},
+		{publishedAt.Format(time.RFC3339), 1.0, 0.5},
+		{publishedAt.Format(time.RFC3339), "Title", "high"},
+		{publishedAt.Format(time.RFC3339), "Title", nil},
+	} {
+		_, err = (&Cursor{Keys: keys}).Values(sort)
+		assert.ErrorIs(t, err, ErrMalformedCursor, keys)
+	}
+	_, err = (&Cursor{Keys: []interface{}{publishedAt.Format(time.RFC3339)}}).Values(sort)
+	assert.ErrorIs(t, err, ErrCursorMismatch)
+}
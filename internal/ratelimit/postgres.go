@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/leguzman/rss-project/internal/database"
+)
+
+const (
+	// Every pruneEvery tokens taken, buckets untouched for staleAfter are
+	// deleted. Any limit refills well within that time.
+	pruneEvery = 1000
+	staleAfter = 24 * time.Hour
+)
+
+// PostgresStore keeps buckets in the rate_limits table, so limits hold
+// across replicas.
+type PostgresStore struct {
+	db    *database.Queries
+	takes atomic.Int64
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) TakeToken(ctx context.Context, key string, capacity, refillRate float64) (float64, bool, error) {
+	if s.takes.Add(1)%pruneEvery == 0 {
+		err := s.db.DeleteStaleRateLimits(ctx, time.Now().UTC().Add(-staleAfter))
+		if err != nil {
+			log.Println("Couldn't prune rate limits:", err)
+		}
+	}
+	row, err := s.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:        key,
+		Capacity:   capacity,
+		RefillRate: refillRate,
+	})
+	return row.Tokens, row.Allowed, err
+}
+
+func (s *PostgresStore) Tokens(ctx context.Context, key string, capacity, refillRate float64) (float64, error) {
+	tokens, err := s.db.GetRateLimitTokens(ctx, database.GetRateLimitTokensParams{
+		Capacity:   capacity,
+		RefillRate: refillRate,
+		Key:        key,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return capacity, nil
+	}
+	return tokens, err
+}
@@ -0,0 +1,181 @@
+// Package ratelimit throttles callers with token buckets: each key may burst
+// up to a limit of requests, then gets tokens back at a steady rate.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leguzman/rss-project/internal/database"
+)
+
+// Limit allows Requests per Period, refilled steadily over the period.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l Limit) refillRate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Store keeps the buckets. TakeToken refills the bucket of key, then takes a
+// token from it if there's one left, returning the tokens remaining. Tokens
+// returns how many tokens the bucket of key holds without taking any, a full
+// bucket's worth for keys never seen.
+type Store interface {
+	TakeToken(ctx context.Context, key string, capacity, refillRate float64) (float64, bool, error)
+	Tokens(ctx context.Context, key string, capacity, refillRate float64) (float64, error)
+}
+
+// Result is the state of a bucket after a request, as reported in the
+// RateLimit-* headers.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, zero when Allowed.
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	store  Store
+	limits map[string]Limit
+}
+
+func NewLimiter(store Store, limits map[string]Limit) *Limiter {
+	return &Limiter{store: store, limits: limits}
+}
+
+// Allow takes a token from the bucket of key in group. Groups without a
+// limit are never throttled, ok is false for them.
+func (l *Limiter) Allow(ctx context.Context, group, key string) (result Result, ok bool, err error) {
+	limit, ok := l.limits[group]
+	if !ok {
+		return Result{Allowed: true}, false, nil
+	}
+	tokens, allowed, err := l.store.TakeToken(ctx, group+":"+key, float64(limit.Requests), limit.refillRate())
+	if err != nil {
+		return Result{Allowed: true}, true, err
+	}
+	return newResult(limit, tokens, allowed), true, nil
+}
+
+// Check reports whether the bucket of key in group has a token left, without
+// taking it. Groups without a limit are never throttled, ok is false for
+// them.
+func (l *Limiter) Check(ctx context.Context, group, key string) (result Result, ok bool, err error) {
+	limit, ok := l.limits[group]
+	if !ok {
+		return Result{Allowed: true}, false, nil
+	}
+	tokens, err := l.store.Tokens(ctx, group+":"+key, float64(limit.Requests), limit.refillRate())
+	if err != nil {
+		return Result{Allowed: true}, true, err
+	}
+	return newResult(limit, tokens, tokens >= 1), true, nil
+}
+
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.refillRate()
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Requests) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
+
+// DefaultLimits apply to the groups RATE_LIMITS doesn't mention. The auth
+// group counts the requests of an IP that failed to authenticate.
+var DefaultLimits = map[string]Limit{
+	"read":   {Requests: 300, Period: time.Minute},
+	"write":  {Requests: 60, Period: time.Minute},
+	"login":  {Requests: 10, Period: time.Minute},
+	"signup": {Requests: 5, Period: time.Hour},
+	"auth":   {Requests: 20, Period: time.Minute},
+}
+
+// ParseLimits parses limits written as "read=300/1m,write=60/1m", the period
+// being a time.Duration. A limit of 0 turns the group off.
+func ParseLimits(value string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		group, spec, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("malformed rate limit %q", part)
+		}
+		requests, period, found := strings.Cut(spec, "/")
+		if !found {
+			return nil, fmt.Errorf("malformed rate limit %q", part)
+		}
+		limit := Limit{}
+		var err error
+		limit.Requests, err = strconv.Atoi(requests)
+		if err != nil || limit.Requests < 0 {
+			return nil, fmt.Errorf("malformed request count in %q", part)
+		}
+		limit.Period, err = time.ParseDuration(period)
+		if err != nil || limit.Period <= 0 {
+			return nil, fmt.Errorf("malformed period in %q", part)
+		}
+		limits[strings.TrimSpace(group)] = limit
+	}
+	return limits, nil
+}
+
+// WithDefaults returns the default limits overridden by limits, leaving out
+// the groups turned off.
+func WithDefaults(limits map[string]Limit) map[string]Limit {
+	merged := map[string]Limit{}
+	for group, limit := range DefaultLimits {
+		merged[group] = limit
+	}
+	for group, limit := range limits {
+		if limit.Requests == 0 {
+			delete(merged, group)
+			continue
+		}
+		merged[group] = limit
+	}
+	return merged
+}
+
+// LimiterFromEnv builds the limiter configured by RATE_LIMITS, in the format
+// of ParseLimits, and RATE_LIMIT_STORE, one of memory (the default), postgres
+// or off. It returns nil when rate limiting is off.
+func LimiterFromEnv(db *database.Queries) (*Limiter, error) {
+	limits, err := ParseLimits(os.Getenv("RATE_LIMITS"))
+	if err != nil {
+		return nil, err
+	}
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		return NewLimiter(NewMemoryStore(), WithDefaults(limits)), nil
+	case "postgres":
+		return NewLimiter(NewPostgresStore(db), WithDefaults(limits)), nil
+	case "off":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q", store)
+	}
+}
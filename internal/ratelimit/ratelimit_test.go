@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	limiter := NewLimiter(store, map[string]Limit{"write": {Requests: 3, Period: 3 * time.Second}})
+	ctx := context.Background()
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, ok, err := limiter.Allow(ctx, "write", "key:a")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+	result, _, _ := limiter.Allow(ctx, "write", "key:a")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 3*time.Second, result.Reset)
+
+	result, _, _ = limiter.Allow(ctx, "write", "key:b")
+	assert.True(t, result.Allowed, "other keys have their own bucket")
+
+	now = now.Add(time.Second)
+	result, _, _ = limiter.Allow(ctx, "write", "key:a")
+	assert.True(t, result.Allowed, "a token came back")
+	result, _, _ = limiter.Allow(ctx, "write", "key:a")
+	assert.False(t, result.Allowed)
+
+	result, ok, _ := limiter.Allow(ctx, "read", "key:a")
+	assert.False(t, ok)
+	assert.True(t, result.Allowed, "groups without a limit aren't throttled")
+
+	result, ok, err := limiter.Check(ctx, "write", "key:d")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 3, result.Remaining, "checking takes no token")
+	result, _, _ = limiter.Check(ctx, "write", "key:a")
+	assert.False(t, result.Allowed)
+
+	now = now.Add(time.Hour)
+	limiter.Allow(ctx, "write", "key:c")
+	assert.Len(t, store.buckets, 1, "full buckets are swept")
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("read=600/1m, signup=0/1h")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Limit{
+		"read":   {Requests: 600, Period: time.Minute},
+		"signup": {Requests: 0, Period: time.Hour},
+	}, limits)
+
+	merged := WithDefaults(limits)
+	assert.Equal(t, Limit{Requests: 600, Period: time.Minute}, merged["read"])
+	assert.Equal(t, DefaultLimits["write"], merged["write"])
+	assert.NotContains(t, merged, "signup")
+
+	for _, value := range []string{"read", "read=10", "read=ten/1m", "read=10/soon", "read=10/0s"} {
+		_, err := ParseLimits(value)
+		assert.Error(t, err, value)
+	}
+}
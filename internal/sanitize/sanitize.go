@@ -0,0 +1,206 @@
+// Package sanitize cleans the HTML publishers put in their feeds before it is
+// stored, so clients can render it without running scripts or loading
+// trackers.
+package sanitize
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowElements("figure", "figcaption", "picture", "audio", "video", "source")
+	p.AllowAttrs("srcset", "sizes", "loading").OnElements("img")
+	p.AllowAttrs("src", "srcset", "sizes", "type", "media").OnElements("source")
+	p.AllowAttrs("src", "controls", "poster").OnElements("audio", "video")
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// trackerHosts serve the invisible images feeds count their readers with.
+var trackerHosts = map[string]bool{
+	"feeds.feedburner.com":        true,
+	"pixel.wp.com":                true,
+	"stats.wordpress.com":         true,
+	"www.google-analytics.com":    true,
+	"pixel.quantserve.com":        true,
+	"feeds.feedblitz.com":         true,
+	"counter.theconversation.com": true,
+}
+
+// urlAttrs are the attributes holding a URL, resolved against the item link.
+var urlAttrs = map[string]bool{"href": true, "src": true, "poster": true}
+
+// HTML returns content limited to safe markup, with URLs made absolute
+// against base (usually the item link) and tracking pixels removed.
+func HTML(content, base string) string {
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil || !baseURL.IsAbs() {
+		baseURL = nil
+	}
+	nodes, err := parseFragment(content)
+	if err != nil {
+		return policy.Sanitize(content)
+	}
+	var b strings.Builder
+	for _, node := range nodes {
+		if isTrackingPixel(node) {
+			continue
+		}
+		clean(node, baseURL)
+		err = html.Render(&b, node)
+		if err != nil {
+			return policy.Sanitize(content)
+		}
+	}
+	return strings.TrimSpace(policy.Sanitize(b.String()))
+}
+
+// Text renders content as plain text, one line per block.
+func Text(content string) string {
+	nodes, err := parseFragment(content)
+	if err != nil {
+		return strings.TrimSpace(content)
+	}
+	var b strings.Builder
+	for _, node := range nodes {
+		writeText(&b, node)
+	}
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func parseFragment(content string) ([]*html.Node, error) {
+	return html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+}
+
+// clean resolves the URLs under node and drops its tracking pixels.
+func clean(node *html.Node, base *url.URL) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		if isTrackingPixel(child) {
+			node.RemoveChild(child)
+		} else {
+			clean(child, base)
+		}
+		child = next
+	}
+	if node.Type != html.ElementNode || base == nil {
+		return
+	}
+	for i, attr := range node.Attr {
+		switch {
+		case urlAttrs[attr.Key]:
+			node.Attr[i].Val = resolve(base, attr.Val)
+		case attr.Key == "srcset":
+			node.Attr[i].Val = resolveSrcset(base, attr.Val)
+		}
+	}
+}
+
+func isTrackingPixel(node *html.Node) bool {
+	if node.Type != html.ElementNode || node.DataAtom != atom.Img {
+		return false
+	}
+	var width, height, src string
+	for _, attr := range node.Attr {
+		switch attr.Key {
+		case "width":
+			width = strings.TrimSpace(attr.Val)
+		case "height":
+			height = strings.TrimSpace(attr.Val)
+		case "src":
+			src = attr.Val
+		}
+	}
+	if (width == "0" || width == "1") && (height == "0" || height == "1") {
+		return true
+	}
+	parsed, err := url.Parse(src)
+	return err == nil && trackerHosts[parsed.Hostname()]
+}
+
+func resolve(base *url.URL, ref string) string {
+	parsed, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// resolveSrcset resolves each candidate of "a.jpg 1x, b.jpg 2x".
+func resolveSrcset(base *url.URL, srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = resolve(base, fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+func writeText(b *strings.Builder, node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		b.WriteString(node.Data)
+		return
+	case html.ElementNode:
+		switch node.DataAtom {
+		case atom.Script, atom.Style, atom.Noscript, atom.Template:
+			return
+		case atom.Br:
+			b.WriteString("\n")
+			return
+		case atom.Img:
+			return
+		}
+	}
+	block := isBlock(node)
+	if block {
+		b.WriteString("\n")
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeText(b, child)
+	}
+	if block {
+		b.WriteString("\n")
+	}
+}
+
+func isBlock(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+	switch node.DataAtom {
+	case atom.P, atom.Div, atom.Li, atom.Ul, atom.Ol, atom.Blockquote, atom.Pre,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Tr, atom.Table,
+		atom.Figure, atom.Figcaption, atom.Section, atom.Article, atom.Hr, atom.Dt, atom.Dd:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package sanitize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTML(t *testing.T) {
+	base := "https://example.com/blog/post-1"
+	assert.Equal(t, `<p>Hello <b>world</b></p>`, HTML(`<p onclick="steal()">Hello <b>world</b></p><script>alert(1)</script>`, base))
+	assert.Equal(t,
+		`<a href="https://example.com/blog/next" rel="nofollow noreferrer noopener" target="_blank">next</a>`,
+		HTML(`<a href="next" target="_self">next</a>`, base))
+	assert.Equal(t,
+		`<img src="https://example.com/img/a.png" srcset="https://example.com/img/a.png 1x, https://example.com/img/b.png 2x"/>`,
+		HTML(`<img src="/img/a.png" srcset="/img/a.png 1x, /img/b.png 2x">`, base))
+	assert.Equal(t, `<p>Read on</p>`, HTML(`<p>Read on<img src="https://t.example.com/p.gif" width="1" height="1"></p>`, base))
+	assert.Equal(t, ``, HTML(`<img src="https://feeds.feedburner.com/~r/blog/~4/abc">`, base))
+	assert.Equal(t, `x`, HTML(`<a href="javascript:alert(1)">x</a>`, base))
+	assert.Equal(t, `Fish &amp; chips`, HTML(`Fish & chips`, ""))
+}
+
+func TestText(t *testing.T) {
+	assert.Equal(t, "Title\n\nFirst paragraph.\n\nSecond\nline\n\nFish & chips", Text(
+		`<h1>Title</h1><p>First   paragraph.</p><p>Second<br>line</p><style>p{}</style><div>Fish &amp; chips</div>`))
+	assert.Equal(t, "plain text", Text("plain text"))
+	assert.Equal(t, "", Text(""))
+}
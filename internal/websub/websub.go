@@ -0,0 +1,159 @@
+// Package websub subscribes to feeds at their WebSub hubs, which then push
+// new content instead of waiting for the feed to be polled.
+//
+// See https://www.w3.org/TR/websub/.
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/fetch"
+)
+
+// DefaultLeaseSeconds is asked of hubs when WEBSUB_LEASE_SECONDS isn't set,
+// ten days like most hubs grant.
+const DefaultLeaseSeconds = 10 * 24 * 60 * 60
+
+// VerifyTimeout is how long a hub has to verify a subscription request,
+// later verifications are refused and the request is made again.
+const VerifyTimeout = time.Hour
+
+// Config configures WebSub, read from the environment like DB_URL and
+// PORT.
+type Config struct {
+	// CallbackURL is where hubs reach this server, the feed ID is appended
+	// to it.
+	CallbackURL  string
+	LeaseSeconds int
+}
+
+// ConfigFromEnv reads WEBSUB_CALLBACK_URL and WEBSUB_LEASE_SECONDS,
+// reporting whether WebSub is enabled at all. Hubs need a public URL to
+// call back, so without one feeds are only polled.
+func ConfigFromEnv() (Config, bool, error) {
+	config := Config{
+		CallbackURL:  os.Getenv("WEBSUB_CALLBACK_URL"),
+		LeaseSeconds: DefaultLeaseSeconds,
+	}
+	if config.CallbackURL == "" {
+		return Config{}, false, nil
+	}
+	if value := os.Getenv("WEBSUB_LEASE_SECONDS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return Config{}, false, errors.New("WEBSUB_LEASE_SECONDS must be a positive number of seconds")
+		}
+		config.LeaseSeconds = n
+	}
+	return config, true, nil
+}
+
+type Subscriber struct {
+	config Config
+	client *fetch.Client
+}
+
+// NewSubscriber makes requests to hubs with client, as their URLs come from
+// feeds.
+func NewSubscriber(config Config, client *fetch.Client) *Subscriber {
+	return &Subscriber{
+		config: config,
+		client: client,
+	}
+}
+
+// LeaseSeconds is how long subscriptions last when the hub doesn't say.
+func (s *Subscriber) LeaseSeconds() int {
+	return s.config.LeaseSeconds
+}
+
+// CallbackURL is where the hub delivers the content of a feed.
+func (s *Subscriber) CallbackURL(feedID uuid.UUID) string {
+	return strings.TrimSuffix(s.config.CallbackURL, "/") + "/" + feedID.String()
+}
+
+// Subscribe asks hub to push topic to the callback of feedID, signing what
+// it sends with secret. The hub confirms later by calling back with a
+// challenge.
+func (s *Subscriber) Subscribe(ctx context.Context, hub, topic string, feedID uuid.UUID, secret string) error {
+	return s.request(ctx, hub, url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {topic},
+		"hub.callback":      {s.CallbackURL(feedID)},
+		"hub.secret":        {secret},
+		"hub.lease_seconds": {strconv.Itoa(s.config.LeaseSeconds)},
+	})
+}
+
+func (s *Subscriber) request(ctx context.Context, hub string, form url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body := resp.Body
+		if len(body) > 512 {
+			body = body[:512]
+		}
+		return fmt.Errorf("hub returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// GenerateSecret returns a secret for a hub to sign content with.
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+var signatureHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// VerifySignature checks an X-Hub-Signature header, "<method>=<hex HMAC>"
+// of body keyed with the subscription secret.
+func VerifySignature(secret, header string, body []byte) bool {
+	method, signature, ok := strings.Cut(header, "=")
+	if !ok {
+		return false
+	}
+	newHash, ok := signatureHashes[strings.ToLower(method)]
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
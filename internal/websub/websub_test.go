@@ -0,0 +1,78 @@
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte("<rss></rss>")
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	assert.True(t, VerifySignature("secret", "sha256="+signature, body))
+	assert.True(t, VerifySignature("secret", "SHA256="+signature, body))
+	assert.False(t, VerifySignature("other", "sha256="+signature, body))
+	assert.False(t, VerifySignature("secret", "sha256="+signature, []byte("<rss/>")))
+	assert.False(t, VerifySignature("secret", "md5="+signature, body))
+	assert.False(t, VerifySignature("secret", signature, body))
+	assert.False(t, VerifySignature("secret", "", body))
+}
+
+func TestSubscribe(t *testing.T) {
+	feedID := uuid.New()
+	var form map[string][]string
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+
+	subscriber := NewSubscriber(
+		Config{CallbackURL: "https://reader.example.com/websub/", LeaseSeconds: 3600},
+		fetch.New(fetch.Config{MaxBodySize: 1 << 20, AllowPrivateNetworks: true}),
+	)
+	err := subscriber.Subscribe(context.Background(), hub.URL, "https://example.com/feed.xml", feedID, "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"subscribe"}, form["hub.mode"])
+	assert.Equal(t, []string{"https://example.com/feed.xml"}, form["hub.topic"])
+	assert.Equal(t, []string{"https://reader.example.com/websub/" + feedID.String()}, form["hub.callback"])
+	assert.Equal(t, []string{"secret"}, form["hub.secret"])
+	assert.Equal(t, []string{"3600"}, form["hub.lease_seconds"])
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown topic", http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	err = subscriber.Subscribe(context.Background(), failing.URL, "https://example.com/feed.xml", feedID, "secret")
+	assert.ErrorContains(t, err, "unknown topic")
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("WEBSUB_CALLBACK_URL", "")
+	_, ok, err := ConfigFromEnv()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	t.Setenv("WEBSUB_CALLBACK_URL", "https://reader.example.com/websub")
+	t.Setenv("WEBSUB_LEASE_SECONDS", "")
+	config, ok, err := ConfigFromEnv()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, DefaultLeaseSeconds, config.LeaseSeconds)
+
+	t.Setenv("WEBSUB_LEASE_SECONDS", "-1")
+	_, _, err = ConfigFromEnv()
+	assert.Error(t, err)
+}
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/leguzman/rss-project/handlers"
+	"github.com/leguzman/rss-project/internal/auth"
 	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/fetch"
+	"github.com/leguzman/rss-project/internal/ingest"
+	"github.com/leguzman/rss-project/internal/ratelimit"
+	"github.com/leguzman/rss-project/internal/websub"
 	"github.com/leguzman/rss-project/routes"
 	_ "github.com/lib/pq"
 )
@@ -24,10 +31,57 @@ func main() {
 		log.Fatal("Can't connect to database: ", err)
 	}
 
+	fetchConfig, err := fetch.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("Can't set up fetching: ", err)
+	}
+	client := fetch.New(fetchConfig)
+	db := database.New(conn)
+	sink := ingest.DBSink{DB: db, Extractor: ingest.NewExtractor(db, client, 4)}
 	apiCfg := handlers.ApiConfig{
-		DB: database.New(conn),
+		DB:       db,
+		Conn:     conn,
+		Fetch:    client,
+		Ingester: ingest.New(ingest.NewHTTPFetcher(client), sink),
+	}
+	if oidcConfig, ok := auth.OIDCConfigFromEnv(); ok {
+		apiCfg.OIDC, err = auth.NewOIDCProvider(context.Background(), oidcConfig)
+		if err != nil {
+			log.Fatal("Can't set up single sign-on: ", err)
+		}
+	}
+	jwtConfig, ok, err := auth.JWTConfigFromEnv()
+	if err != nil {
+		log.Fatal("Can't set up bearer tokens: ", err)
+	}
+	if ok {
+		apiCfg.JWT, err = auth.NewJWTVerifier(jwtConfig)
+		if err != nil {
+			log.Fatal("Can't set up bearer tokens: ", err)
+		}
+	}
+	if value := os.Getenv("TRUST_PROXY_HEADERS"); value != "" {
+		apiCfg.TrustProxyHeaders, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Fatal("TRUST_PROXY_HEADERS must be true or false")
+		}
+	}
+	apiCfg.RateLimiter, err = ratelimit.LimiterFromEnv(apiCfg.DB)
+	if err != nil {
+		log.Fatal("Can't set up rate limiting: ", err)
+	}
+	apiCfg.Signup, err = auth.SignupConfigFromEnv()
+	if err != nil {
+		log.Fatal("Can't set up signup: ", err)
+	}
+	webSubConfig, ok, err := websub.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("Can't set up WebSub: ", err)
+	}
+	if ok {
+		apiCfg.WebSub = websub.NewSubscriber(webSubConfig, client)
 	}
-	go startScraping(apiCfg.DB, 10, time.Minute)
+	go startScraping(apiCfg.DB, apiCfg.Ingester, apiCfg.WebSub, 10, time.Minute)
 
 	server := &http.Server{
 		Handler: routes.GetRouter(apiCfg),
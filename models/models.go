@@ -1,10 +1,13 @@
 package models
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/sanitize"
 )
 
 type User struct {
@@ -12,7 +15,98 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Name      string    `json:"name"`
-	APIKey    string    `json:"api_key"`
+	FeedToken string    `json:"feed_token"`
+}
+
+// UpdatedUser is a user after an update, with a warning about what the
+// update broke.
+type UpdatedUser struct {
+	User
+	Warning string `json:"warning,omitempty"`
+}
+
+// UserWithApiKey is only returned when the user is created, the secret of
+// its first API key can't be recovered afterwards.
+type UserWithApiKey struct {
+	User
+	APIKey string `json:"api_key"`
+}
+
+type ApiKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Scopes     []string   `json:"scopes"`
+}
+
+// CreatedApiKey is only returned when the key is created.
+type CreatedApiKey struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+// AdminUser is a user as seen by admins, without its secrets.
+type AdminUser struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Name        string     `json:"name"`
+	Email       *string    `json:"email"`
+	IsAdmin     bool       `json:"is_admin"`
+	DisabledAt  *time.Time `json:"disabled_at"`
+	HasPassword bool       `json:"has_password"`
+	SSO         bool       `json:"sso"`
+}
+
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	Current    bool       `json:"current"`
+}
+
+// CreatedSession is only returned when logging in, front-ends echo its
+// CSRFToken in the X-CSRF-Token header of requests that change state.
+type CreatedSession struct {
+	Session
+	User      User   `json:"user"`
+	CSRFToken string `json:"csrf_token"`
+}
+
+// OIDCLink is where front-ends send the browser to link an identity to the
+// logged in user.
+type OIDCLink struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// SignupChallenge is solved by finding a nonce such that
+// sha256("<challenge>:<nonce>") starts with Difficulty zero bits.
+type SignupChallenge struct {
+	Challenge  string    `json:"challenge"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type Invitation struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	CreatedBy *uuid.UUID `json:"created_by"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	UsedBy    *uuid.UUID `json:"used_by"`
+}
+
+// CreatedInvitation is only returned when creating an invitation, its Code
+// can't be recovered afterwards.
+type CreatedInvitation struct {
+	Invitation
+	Code string `json:"code"`
 }
 
 type Feed struct {
@@ -21,14 +115,68 @@ type Feed struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Name      string    `json:"name"`
 	Url       string    `json:"url"`
-	UserId    uuid.UUID `json:"user_id"`
+	// UserId is who added the feed, nil once they deleted their account.
+	UserId *uuid.UUID `json:"user_id"`
+	// Kind is "rss", or "scraped" for a web page read with ScrapeConfig.
+	Kind         string          `json:"kind"`
+	ScrapeConfig json.RawMessage `json:"scrape_config,omitempty"`
+}
+
+// FeedHealth is a feed as seen by admins, with how its fetches go.
+type FeedHealth struct {
+	Feed
+	LastFetchedAt   *time.Time `json:"last_fetched_at"`
+	LastFetchError  *string    `json:"last_fetch_error"`
+	FetchErrorCount int32      `json:"fetch_error_count"`
+	// ParseWarning is set when the feed is malformed but could be read
+	// after repairing it.
+	ParseWarning  *string `json:"parse_warning"`
+	FollowerCount int64   `json:"follower_count"`
+	PostCount     int64   `json:"post_count"`
+}
+
+type Stats struct {
+	Users          int64 `json:"users"`
+	DisabledUsers  int64 `json:"disabled_users"`
+	Feeds          int64 `json:"feeds"`
+	FailingFeeds   int64 `json:"failing_feeds"`
+	FeedFollows    int64 `json:"feed_follows"`
+	Posts          int64 `json:"posts"`
+	ActiveApiKeys  int64 `json:"active_api_keys"`
+	ActiveSessions int64 `json:"active_sessions"`
+}
+
+// FeedValidation is whether a feed is well-formed RSS, checked before adding
+// it or to find out why its posts look wrong.
+type FeedValidation struct {
+	Url   string `json:"url"`
+	Valid bool   `json:"valid"`
+	// Error is what makes the feed invalid, nil when it is valid.
+	Error *string `json:"error"`
+	// Recoverable is whether the scraper still reads an invalid feed, by
+	// repairing it.
+	Recoverable bool `json:"recoverable"`
+	Items       int  `json:"items"`
 }
+
+// FeedPreviewItem is a post as it would be stored from a feed not added
+// yet.
+type FeedPreviewItem struct {
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
 type FeedFollow struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	UserID    uuid.UUID `json:"user_id"`
 	FeedID    uuid.UUID `json:"feed_id"`
+	// ExtractFullContent fetches the linked page of new posts for the
+	// article, for feeds that only publish a teaser.
+	ExtractFullContent bool `json:"extract_full_content"`
 }
 type Post struct {
 	ID          uuid.UUID `json:"id"`
@@ -36,13 +184,73 @@ type Post struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
-	PublishedAt time.Time `json:"published_at"`
-	Url         string    `json:"url"`
-	FeedID      uuid.UUID `json:"feed_id"`
+	// DescriptionText is Description, or Content if it has none, as plain
+	// text.
+	DescriptionText string    `json:"description_text"`
+	PublishedAt     time.Time `json:"published_at"`
+	Url             string    `json:"url"`
+	FeedID          uuid.UUID `json:"feed_id"`
+	// Content is the full text of the post, Description often a teaser.
+	Content string `json:"content"`
+	// ExtractedContent is the article found on the linked page, when the
+	// feed is followed with extract_full_content.
+	ExtractedContent string     `json:"extracted_content"`
+	ExtractedAt      *time.Time `json:"extracted_at"`
+	Authors          []string   `json:"authors"`
+	Categories       []string   `json:"categories"`
+	// Episode and Season number podcast episodes.
+	Episode     *int32       `json:"episode"`
+	Season      *int32       `json:"season"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment is a media file of a post: a podcast enclosure ("enclosure"), a
+// Media RSS file ("media") or thumbnail ("thumbnail"), or the episode art
+// ("image").
+type Attachment struct {
+	Kind            string  `json:"kind"`
+	Url             string  `json:"url"`
+	MimeType        *string `json:"mime_type"`
+	Length          *int64  `json:"length"`
+	DurationSeconds *int32  `json:"duration_seconds"`
+	Width           *int32  `json:"width"`
+	Height          *int32  `json:"height"`
+}
+
+// PostState is whether a user read or starred a post, as found in their
+// data export.
+type PostState struct {
+	PostID    uuid.UUID `json:"post_id"`
+	Url       string    `json:"url"`
+	Title     string    `json:"title"`
+	IsRead    bool      `json:"is_read"`
+	IsStarred bool      `json:"is_starred"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func DBPostStateToPostState(DbPostState database.GetUserPostStatesRow) PostState {
+	return PostState{
+		PostID:    DbPostState.PostID,
+		Url:       DbPostState.Url,
+		Title:     DbPostState.Title,
+		IsRead:    DbPostState.IsRead,
+		IsStarred: DbPostState.IsStarred,
+		UpdatedAt: DbPostState.UpdatedAt,
+	}
+}
+
+// DescriptionHTML is the description of a post as markup safe to show. Posts
+// stored before ingestion sanitized them have no descriptionText, their
+// markup is untrusted and gets sanitized here.
+func DescriptionHTML(description, descriptionText sql.NullString, url string) string {
+	if descriptionText.Valid {
+		return description.String
+	}
+	return sanitize.HTML(description.String, url)
 }
 
 func DBPostToPost(DbPost database.Post) Post {
-	return Post{
+	post := Post{
 		ID:          DbPost.ID,
 		CreatedAt:   DbPost.CreatedAt,
 		UpdatedAt:   DbPost.UpdatedAt,
@@ -50,7 +258,60 @@ func DBPostToPost(DbPost database.Post) Post {
 		Description: DbPost.Description.String,
 		PublishedAt: DbPost.PublishedAt,
 		Url:         DbPost.Url,
+		Content:     DbPost.Content.String,
+		// Sanitized when extracted, unlike the legacy rows below.
+		ExtractedContent: DbPost.ExtractedContent.String,
+		Authors:          DbPost.Authors,
+		Categories:       []string{},
+		Attachments:      []Attachment{},
+	}
+	if post.Authors == nil {
+		post.Authors = []string{}
 	}
+	post.Description = DescriptionHTML(DbPost.Description, DbPost.DescriptionText, post.Url)
+	if DbPost.DescriptionText.Valid {
+		post.DescriptionText = DbPost.DescriptionText.String
+	} else {
+		post.Content = sanitize.HTML(post.Content, post.Url)
+		text := post.Description
+		if text == "" {
+			text = post.Content
+		}
+		post.DescriptionText = sanitize.Text(text)
+	}
+	if DbPost.ExtractedAt.Valid {
+		post.ExtractedAt = &DbPost.ExtractedAt.Time
+	}
+	if DbPost.Episode.Valid {
+		post.Episode = &DbPost.Episode.Int32
+	}
+	if DbPost.Season.Valid {
+		post.Season = &DbPost.Season.Int32
+	}
+	return post
+}
+
+func DBPostAttachmentToAttachment(DbAttachment database.PostAttachment) Attachment {
+	attachment := Attachment{
+		Kind: DbAttachment.Kind,
+		Url:  DbAttachment.Url,
+	}
+	if DbAttachment.MimeType.Valid {
+		attachment.MimeType = &DbAttachment.MimeType.String
+	}
+	if DbAttachment.Length.Valid {
+		attachment.Length = &DbAttachment.Length.Int64
+	}
+	if DbAttachment.DurationSeconds.Valid {
+		attachment.DurationSeconds = &DbAttachment.DurationSeconds.Int32
+	}
+	if DbAttachment.Width.Valid {
+		attachment.Width = &DbAttachment.Width.Int32
+	}
+	if DbAttachment.Height.Valid {
+		attachment.Height = &DbAttachment.Height.Int32
+	}
+	return attachment
 }
 func DBUserToUser(Dbuser database.User) User {
 	return User{
@@ -58,28 +319,151 @@ func DBUserToUser(Dbuser database.User) User {
 		CreatedAt: Dbuser.CreatedAt,
 		UpdatedAt: Dbuser.UpdatedAt,
 		Name:      Dbuser.Name,
-		APIKey:    Dbuser.ApiKey,
+		FeedToken: Dbuser.FeedToken,
+	}
+}
+
+func DBUserToAdminUser(DbUser database.User) AdminUser {
+	user := AdminUser{
+		ID:          DbUser.ID,
+		CreatedAt:   DbUser.CreatedAt,
+		UpdatedAt:   DbUser.UpdatedAt,
+		Name:        DbUser.Name,
+		IsAdmin:     DbUser.IsAdmin,
+		HasPassword: DbUser.PasswordHash.Valid,
+		SSO:         DbUser.OidcSubject.Valid,
+	}
+	if DbUser.Email.Valid {
+		user.Email = &DbUser.Email.String
+	}
+	if DbUser.DisabledAt.Valid {
+		user.DisabledAt = &DbUser.DisabledAt.Time
+	}
+	return user
+}
+
+func DBFeedHealthToFeedHealth(DbFeed database.GetFeedsWithHealthRow) FeedHealth {
+	feed := FeedHealth{
+		Feed: Feed{
+			ID:        DbFeed.ID,
+			CreatedAt: DbFeed.CreatedAt,
+			UpdatedAt: DbFeed.UpdatedAt,
+			Name:      DbFeed.Name,
+			Url:       DbFeed.Url,
+			Kind:      DbFeed.Kind,
+		},
+		FetchErrorCount: DbFeed.FetchErrorCount,
+		FollowerCount:   DbFeed.FollowerCount,
+		PostCount:       DbFeed.PostCount,
+	}
+	if DbFeed.UserID.Valid {
+		feed.UserId = &DbFeed.UserID.UUID
+	}
+	if DbFeed.Kind == "scraped" {
+		feed.ScrapeConfig = DbFeed.ScrapeConfig
+	}
+	if DbFeed.LastFetchedAt.Valid {
+		feed.LastFetchedAt = &DbFeed.LastFetchedAt.Time
+	}
+	if DbFeed.LastFetchError.Valid {
+		feed.LastFetchError = &DbFeed.LastFetchError.String
+	}
+	if DbFeed.ParseWarning.Valid {
+		feed.ParseWarning = &DbFeed.ParseWarning.String
 	}
+	return feed
+}
+
+func DBStatsToStats(DbStats database.GetStatsRow) Stats {
+	return Stats{
+		Users:          DbStats.Users,
+		DisabledUsers:  DbStats.DisabledUsers,
+		Feeds:          DbStats.Feeds,
+		FailingFeeds:   DbStats.FailingFeeds,
+		FeedFollows:    DbStats.FeedFollows,
+		Posts:          DbStats.Posts,
+		ActiveApiKeys:  DbStats.ActiveApiKeys,
+		ActiveSessions: DbStats.ActiveSessions,
+	}
+}
+
+func DBApiKeyToApiKey(DbApiKey database.ApiKey) ApiKey {
+	apiKey := ApiKey{
+		ID:        DbApiKey.ID,
+		CreatedAt: DbApiKey.CreatedAt,
+		Name:      DbApiKey.Name,
+		Prefix:    DbApiKey.Prefix,
+		Scopes:    DbApiKey.Scopes,
+	}
+	if DbApiKey.LastUsedAt.Valid {
+		apiKey.LastUsedAt = &DbApiKey.LastUsedAt.Time
+	}
+	if DbApiKey.ExpiresAt.Valid {
+		apiKey.ExpiresAt = &DbApiKey.ExpiresAt.Time
+	}
+	return apiKey
+}
+
+func DBSessionToSession(DbSession database.Session) Session {
+	session := Session{
+		ID:        DbSession.ID,
+		CreatedAt: DbSession.CreatedAt,
+		ExpiresAt: DbSession.ExpiresAt,
+		UserAgent: DbSession.UserAgent,
+		IP:        DbSession.Ip,
+	}
+	if DbSession.LastUsedAt.Valid {
+		session.LastUsedAt = &DbSession.LastUsedAt.Time
+	}
+	return session
+}
+
+func DBInvitationToInvitation(DbInvitation database.Invitation) Invitation {
+	invitation := Invitation{
+		ID:        DbInvitation.ID,
+		CreatedAt: DbInvitation.CreatedAt,
+	}
+	if DbInvitation.CreatedBy.Valid {
+		invitation.CreatedBy = &DbInvitation.CreatedBy.UUID
+	}
+	if DbInvitation.ExpiresAt.Valid {
+		invitation.ExpiresAt = &DbInvitation.ExpiresAt.Time
+	}
+	if DbInvitation.UsedAt.Valid {
+		invitation.UsedAt = &DbInvitation.UsedAt.Time
+	}
+	if DbInvitation.UsedBy.Valid {
+		invitation.UsedBy = &DbInvitation.UsedBy.UUID
+	}
+	return invitation
 }
 
 func DBFeedToFeed(DbFeed database.Feed) Feed {
-	return Feed{
+	feed := Feed{
 		ID:        DbFeed.ID,
 		CreatedAt: DbFeed.CreatedAt,
 		UpdatedAt: DbFeed.UpdatedAt,
 		Name:      DbFeed.Name,
 		Url:       DbFeed.Url,
-		UserId:    DbFeed.UserID,
+		Kind:      DbFeed.Kind,
+	}
+	if DbFeed.UserID.Valid {
+		feed.UserId = &DbFeed.UserID.UUID
+	}
+	if DbFeed.Kind == "scraped" {
+		feed.ScrapeConfig = DbFeed.ScrapeConfig
 	}
+	return feed
 }
 
 func DBFeedFollowToFeedFollow(DbFeedFollow database.FeedFollow) FeedFollow {
 	return FeedFollow{
-		ID:        DbFeedFollow.ID,
-		CreatedAt: DbFeedFollow.CreatedAt,
-		UpdatedAt: DbFeedFollow.UpdatedAt,
-		UserID:    DbFeedFollow.UserID,
-		FeedID:    DbFeedFollow.FeedID,
+		ID:                 DbFeedFollow.ID,
+		CreatedAt:          DbFeedFollow.CreatedAt,
+		UpdatedAt:          DbFeedFollow.UpdatedAt,
+		UserID:             DbFeedFollow.UserID,
+		FeedID:             DbFeedFollow.FeedID,
+		ExtractFullContent: DbFeedFollow.ExtractFullContent,
 	}
 }
 
@@ -106,3 +490,11 @@ func DBPostsToPosts(DbPosts []database.Post) []Post {
 	}
 	return posts
 }
+
+func DBApiKeysToApiKeys(DbApiKeys []database.ApiKey) []ApiKey {
+	apiKeys := []ApiKey{}
+	for _, DbApiKey := range DbApiKeys {
+		apiKeys = append(apiKeys, DBApiKeyToApiKey(DbApiKey))
+	}
+	return apiKeys
+}
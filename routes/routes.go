@@ -7,6 +7,7 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/leguzman/rss-project/handlers"
+	"github.com/leguzman/rss-project/internal/auth"
 )
 
 func GetRouter(apiCfg handlers.ApiConfig) chi.Router {
@@ -14,9 +15,9 @@ func GetRouter(apiCfg handlers.ApiConfig) chi.Router {
 	router.Use(middleware.Logger)
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
 		AllowedHeaders:   []string{"*"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -24,24 +25,93 @@ func GetRouter(apiCfg handlers.ApiConfig) chi.Router {
 		w.Write([]byte("Hello World!"))
 	})
 
+	// Authenticated routes are limited per API key or user, the others per IP.
+	read := apiCfg.MiddlewareRateLimit("read")
+	write := apiCfg.MiddlewareRateLimit("write")
+	readByIP := apiCfg.MiddlewareRateLimitByIP("read")
+	login := apiCfg.MiddlewareRateLimitByIP("login")
+	signup := apiCfg.MiddlewareRateLimitByIP("signup")
+
 	v1Router := chi.NewRouter()
 	v1Router.Get("/healthz", handlers.HandlerReadiness)
 	v1Router.Get("/err", handlers.HandlerError)
 
-	v1Router.Post("/users", apiCfg.HandlerCreateUser)
-	v1Router.Get("/users", apiCfg.MiddlewareAuth(apiCfg.HandlerGetUser))
+	v1Router.With(signup).Post("/users", apiCfg.HandlerCreateUser)
+	v1Router.With(readByIP).Post("/users/challenge", apiCfg.HandlerCreateSignupChallenge)
+	v1Router.With(read).Get("/users", apiCfg.MiddlewareAuth(auth.ScopeRead, apiCfg.HandlerGetUser))
+	v1Router.With(write).Patch("/users", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerUpdateUser))
+	v1Router.With(write).Delete("/users", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerDeleteUser))
+	v1Router.With(read).Get("/users/export", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerExportUser))
+	v1Router.With(write).Post("/users/feed_token", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerRegenerateFeedToken))
+	v1Router.With(write).Put("/users/password", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerSetPassword))
+
+	v1Router.With(login).Post("/login", apiCfg.HandlerLogin)
+	v1Router.With(login).Get("/auth/oidc/login", apiCfg.HandlerOIDCLogin)
+	v1Router.With(login).Get("/auth/oidc/callback", apiCfg.HandlerOIDCCallback)
+	v1Router.With(write).Post("/auth/oidc/link", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerOIDCLink))
+	v1Router.With(write).Post("/logout", apiCfg.MiddlewareAuth(auth.ScopeRead, apiCfg.HandlerLogout))
+	v1Router.With(read).Get("/sessions", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerGetSessions))
+	v1Router.With(write).Delete("/sessions/{sessionID}", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerRevokeSession))
+
+	v1Router.With(readByIP).Get("/timeline/{feedToken}/{format}", apiCfg.HandlerGetTimeline)
+
+	v1Router.With(write).Post("/api_keys", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerCreateApiKey))
+	v1Router.With(read).Get("/api_keys", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerGetApiKeys))
+	v1Router.With(write).Post("/api_keys/rotate", apiCfg.MiddlewareAuth(auth.ScopeRead, apiCfg.HandlerRotateApiKey))
+	v1Router.With(write).Delete("/api_keys/{apiKeyID}", apiCfg.MiddlewareAuth(auth.ScopeAdmin, apiCfg.HandlerRevokeApiKey))
+
+	v1Router.With(write).Post("/feeds", apiCfg.MiddlewareAuth(auth.ScopeFeeds, apiCfg.HandlerCreateFeed))
+	v1Router.With(readByIP).Get("/feeds", apiCfg.HandlerGetFeeds)
+	v1Router.With(write).Post("/feeds/preview", apiCfg.MiddlewareAuth(auth.ScopeFeeds, apiCfg.HandlerPreviewFeed))
+	v1Router.With(write).Post("/feeds/validate", apiCfg.MiddlewareAuth(auth.ScopeFeeds, apiCfg.HandlerValidateFeed))
 
-	v1Router.Post("/feeds", apiCfg.MiddlewareAuth(apiCfg.HandlerCreateFeed))
-	v1Router.Get("/feeds", apiCfg.HandlerGetFeeds)
+	v1Router.With(write).Post("/feed_follows", apiCfg.MiddlewareAuth(auth.ScopeFeeds, apiCfg.HandlerCreateFeedFollow))
+	v1Router.With(read).Get("/feed_follows", apiCfg.MiddlewareAuth(auth.ScopeRead, apiCfg.HandlerGetFeedFollows))
+	v1Router.With(write).Patch("/feed_follows/{feedFollowID}", apiCfg.MiddlewareAuth(auth.ScopeFeeds, apiCfg.HandlerUpdateFeedFollow))
+	v1Router.With(write).Delete("/feed_follows/{feedFollowID}", apiCfg.MiddlewareAuth(auth.ScopeFeeds, apiCfg.HandlerDeleteFeedFollow))
 
-	v1Router.Post("/feed_follows", apiCfg.MiddlewareAuth(apiCfg.HandlerCreateFeedFollow))
-	v1Router.Get("/feed_follows", apiCfg.MiddlewareAuth(apiCfg.HandlerGetFeedFollows))
-	v1Router.Delete("/feed_follows/{feedFollowID}", apiCfg.MiddlewareAuth(apiCfg.HandlerDeleteFeedFollow))
+	v1Router.With(read).Get("/posts", apiCfg.MiddlewareAuth(auth.ScopeRead, apiCfg.HandlerGetUserPosts))
+	v1Router.With(read).Get("/post", apiCfg.MiddlewareAuth(auth.ScopeRead, apiCfg.HandlerFilterUserPosts))
+	v1Router.With(write).Post("/posts/{postID}/extract", apiCfg.MiddlewareAuth(auth.ScopeFeeds, apiCfg.HandlerExtractPost))
 
-	v1Router.Get("/posts", apiCfg.MiddlewareAuth(apiCfg.HandlerGetUserPosts))
-	v1Router.Get("/post", apiCfg.MiddlewareAuth(apiCfg.HandlerFilterUserPosts))
+	adminRouter := chi.NewRouter()
+	adminRouter.With(read).Get("/users", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminGetUsers))
+	adminRouter.With(write).Post("/users", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminCreateUser))
+	adminRouter.With(write).Patch("/users/{userID}", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminUpdateUser))
+	adminRouter.With(write).Delete("/users/{userID}", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminDeleteUser))
+	adminRouter.With(read).Get("/feeds", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminGetFeeds))
+	adminRouter.With(write).Post("/feeds/{feedID}/refresh", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminRefreshFeed))
+	adminRouter.With(write).Delete("/feeds/{feedID}", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminDeleteFeed))
+	adminRouter.With(write).Post("/invitations", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminCreateInvitation))
+	adminRouter.With(read).Get("/invitations", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminGetInvitations))
+	adminRouter.With(write).Delete("/invitations/{invitationID}", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminDeleteInvitation))
+	adminRouter.With(read).Get("/stats", apiCfg.MiddlewareAdmin(apiCfg.HandlerAdminGetStats))
+	v1Router.Mount("/admin", adminRouter)
 
 	router.Mount("/v1", v1Router)
 
+	greaderRouter := chi.NewRouter()
+	greaderRouter.With(login).Post("/accounts/ClientLogin", apiCfg.HandlerGReaderClientLogin)
+	greaderRouter.With(read).Get("/reader/api/0/token", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderToken))
+	greaderRouter.With(read).Get("/reader/api/0/user-info", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderUserInfo))
+	greaderRouter.With(read).Get("/reader/api/0/tag/list", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderTagList))
+	greaderRouter.With(read).Get("/reader/api/0/subscription/list", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderSubscriptionList))
+	greaderRouter.With(write).Post("/reader/api/0/subscription/edit", apiCfg.MiddlewareGoogleLogin(auth.ScopeFeeds, apiCfg.HandlerGReaderSubscriptionEdit))
+	greaderRouter.With(read).Get("/reader/api/0/unread-count", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderUnreadCount))
+	greaderRouter.With(read).Get("/reader/api/0/stream/contents/*", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderStreamContents))
+	greaderRouter.With(read).Get("/reader/api/0/stream/items/ids", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderStreamItemIDs))
+	greaderRouter.With(read).Post("/reader/api/0/stream/items/contents", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderStreamItemContents))
+	greaderRouter.With(write).Post("/reader/api/0/edit-tag", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderEditTag))
+	greaderRouter.With(write).Post("/reader/api/0/mark-all-as-read", apiCfg.MiddlewareGoogleLogin(auth.ScopeRead, apiCfg.HandlerGReaderMarkAllAsRead))
+
+	router.Mount("/api/greader", greaderRouter)
+
+	// Hubs call back here, see WEBSUB_CALLBACK_URL.
+	router.With(readByIP).Get("/websub/{feedID}", apiCfg.HandlerWebSubVerify)
+	router.With(readByIP).Post("/websub/{feedID}", apiCfg.HandlerWebSubPush)
+
+	router.With(readByIP).HandleFunc("/fever", apiCfg.HandlerFever)
+	router.With(readByIP).HandleFunc("/fever/", apiCfg.HandlerFever)
+
 	return router
 }
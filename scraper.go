@@ -4,23 +4,52 @@ import (
 	"context"
 	"database/sql"
 	"log"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/leguzman/rss-project/handlers"
 	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/ingest"
+	"github.com/leguzman/rss-project/internal/websub"
 )
 
+// scraperStore is the bookkeeping the scraper does besides storing posts,
+// implemented by *database.Queries.
+type scraperStore interface {
+	webSubStore
+	GetNextFeedsToFetch(ctx context.Context, limit int32) ([]database.Feed, error)
+	MarkFeedAsFetched(ctx context.Context, id uuid.UUID) (database.Feed, error)
+	RecordFeedFetchError(ctx context.Context, arg database.RecordFeedFetchErrorParams) error
+	ClearFeedFetchError(ctx context.Context, id uuid.UUID) error
+	SetFeedParseWarning(ctx context.Context, arg database.SetFeedParseWarningParams) error
+	DeleteExpiredSignupChallenges(ctx context.Context) error
+	DeleteExpiredGReaderTokens(ctx context.Context) error
+}
+
+// startScraping polls feeds forever. subscriber is nil unless WebSub is
+// enabled, feeds with a hub are then subscribed to and polled less. Expired
+// signup challenges and Google Reader tokens are cleaned up on the way.
 func startScraping(
-	db *database.Queries,
+	db scraperStore,
+	ingester *ingest.Ingester,
+	subscriber *websub.Subscriber,
 	concurrency int,
 	timeBetweenRequest time.Duration,
 ) {
 	log.Printf("Scraping on %v goroutines every %d minute(s)", concurrency, timeBetweenRequest/time.Minute)
 	ticker := time.NewTicker(timeBetweenRequest)
 	for ; ; <-ticker.C {
+		if subscriber != nil {
+			renewWebSubLeases(subscriber, db)
+		}
+		err := db.DeleteExpiredSignupChallenges(context.Background())
+		if err != nil {
+			log.Println("Error deleting expired signup challenges:", err)
+		}
+		err = db.DeleteExpiredGReaderTokens(context.Background())
+		if err != nil {
+			log.Println("Error deleting expired Google Reader tokens:", err)
+		}
 		feeds, err := db.GetNextFeedsToFetch(
 			context.Background(),
 			int32(concurrency),
@@ -31,52 +60,48 @@ func startScraping(
 		wg := &sync.WaitGroup{}
 		for _, feed := range feeds {
 			wg.Add(1)
-			go scrapeFeed(db, wg, feed)
+			go scrapeFeed(db, ingester, subscriber, wg, feed)
 		}
 		wg.Wait()
 	}
 }
 
-func scrapeFeed(db *database.Queries, wg *sync.WaitGroup, feed database.Feed) {
+func scrapeFeed(db scraperStore, ingester *ingest.Ingester, subscriber *websub.Subscriber, wg *sync.WaitGroup, feed database.Feed) {
 	defer wg.Done()
 	_, err := db.MarkFeedAsFetched(context.Background(), feed.ID)
 	if err != nil {
 		log.Println("Error marking feed:", err)
 		return
 	}
-	rssFeed, err := handlers.UrlToFeed(feed.Url)
+	result, err := ingester.Ingest(context.Background(), ingest.FeedFromDB(feed))
 	if err != nil {
-		log.Println("Error fetching feed:", feed)
+		log.Printf("Error fetching feed %s: %v", feed.Url, err)
+		err = db.RecordFeedFetchError(context.Background(), database.RecordFeedFetchErrorParams{
+			ID:             feed.ID,
+			LastFetchError: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if err != nil {
+			log.Println("Error recording feed error:", err)
+		}
 		return
 	}
-	for _, item := range rssFeed.Channel.Item {
-		desc := sql.NullString{}
-		if item.Description != "" {
-			desc = sql.NullString{
-				Valid:  true,
-				String: item.Description,
-			}
-		}
-		pubDate, err := time.Parse(time.RFC1123Z, item.PubDate)
+	if feed.FetchErrorCount > 0 {
+		err = db.ClearFeedFetchError(context.Background(), feed.ID)
 		if err != nil {
-			log.Printf("Couldn't parse date %v, err: %v", item.PubDate, err)
+			log.Println("Error clearing feed error:", err)
 		}
-		_, err = db.CreatePost(context.Background(), database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   time.Now().UTC(),
-			UpdatedAt:   time.Now().UTC(),
-			Title:       item.Title,
-			Description: desc,
-			PublishedAt: pubDate,
-			Url:         item.Link,
-			FeedID:      feed.ID,
+	}
+	if result.Warning != feed.ParseWarning.String {
+		err = db.SetFeedParseWarning(context.Background(), database.SetFeedParseWarningParams{
+			ID:           feed.ID,
+			ParseWarning: sql.NullString{String: result.Warning, Valid: result.Warning != ""},
 		})
 		if err != nil {
-			if strings.Contains(err.Error(), "duplicate key") {
-				continue
-			}
-			log.Println("Couldn't create post: ", err)
+			log.Println("Error recording parse warning:", err)
 		}
 	}
-	log.Printf("Feed %s collected, %d posts found", feed.Name, len(rssFeed.Channel.Item))
+	log.Printf("Feed %s collected, %d posts found, %d new", feed.Name, len(result.Items), result.Created)
+	if subscriber != nil && result.Hub != "" {
+		subscribeWebSub(subscriber, db, feed, result.Hub, result.Topic)
+	}
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/fetch"
+	"github.com/leguzman/rss-project/internal/ingest"
+	"github.com/leguzman/rss-project/internal/websub"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStore struct {
+	fetched       []uuid.UUID
+	fetchErrors   map[uuid.UUID]string
+	cleared       []uuid.UUID
+	warnings      map[uuid.UUID]sql.NullString
+	subscriptions map[uuid.UUID]database.WebsubSubscription
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		fetchErrors:   map[uuid.UUID]string{},
+		warnings:      map[uuid.UUID]sql.NullString{},
+		subscriptions: map[uuid.UUID]database.WebsubSubscription{},
+	}
+}
+
+func (s *fakeStore) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]database.Feed, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) MarkFeedAsFetched(ctx context.Context, id uuid.UUID) (database.Feed, error) {
+	s.fetched = append(s.fetched, id)
+	return database.Feed{ID: id}, nil
+}
+
+func (s *fakeStore) RecordFeedFetchError(ctx context.Context, arg database.RecordFeedFetchErrorParams) error {
+	s.fetchErrors[arg.ID] = arg.LastFetchError.String
+	return nil
+}
+
+func (s *fakeStore) ClearFeedFetchError(ctx context.Context, id uuid.UUID) error {
+	s.cleared = append(s.cleared, id)
+	return nil
+}
+
+func (s *fakeStore) SetFeedParseWarning(ctx context.Context, arg database.SetFeedParseWarningParams) error {
+	s.warnings[arg.ID] = arg.ParseWarning
+	return nil
+}
+
+func (s *fakeStore) DeleteExpiredSignupChallenges(ctx context.Context) error {
+	return nil
+}
+
+func (s *fakeStore) DeleteExpiredGReaderTokens(ctx context.Context) error {
+	return nil
+}
+
+func (s *fakeStore) GetWebSubSubscription(ctx context.Context, feedID uuid.UUID) (database.WebsubSubscription, error) {
+	subscription, ok := s.subscriptions[feedID]
+	if !ok {
+		return database.WebsubSubscription{}, sql.ErrNoRows
+	}
+	return subscription, nil
+}
+
+func (s *fakeStore) SubscribeWebSub(ctx context.Context, arg database.SubscribeWebSubParams) (database.WebsubSubscription, error) {
+	subscription := database.WebsubSubscription{
+		FeedID:      arg.FeedID,
+		Hub:         arg.Hub,
+		Topic:       arg.Topic,
+		Secret:      arg.Secret,
+		State:       "pending",
+		RequestedAt: time.Now().UTC(),
+	}
+	s.subscriptions[arg.FeedID] = subscription
+	return subscription, nil
+}
+
+func (s *fakeStore) GetWebSubSubscriptionsToRenew(ctx context.Context) ([]database.WebsubSubscription, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) MarkWebSubRenewalRequested(ctx context.Context, feedID uuid.UUID) error {
+	return nil
+}
+
+type fakeFetcher map[string]string
+
+func (f fakeFetcher) Fetch(ctx context.Context, url string) (ingest.Document, error) {
+	body, ok := f[url]
+	if !ok {
+		return ingest.Document{}, errors.New("not found")
+	}
+	return ingest.Document{URL: url, ContentType: "application/rss+xml", Body: []byte(body)}, nil
+}
+
+type countingSink struct{}
+
+func (countingSink) Store(ctx context.Context, feed ingest.Feed, items []ingest.Item) (int, error) {
+	return len(items), nil
+}
+
+func scrape(db scraperStore, fetcher fakeFetcher, subscriber *websub.Subscriber, feed database.Feed) {
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	scrapeFeed(db, ingest.New(fetcher, countingSink{}), subscriber, wg, feed)
+}
+
+func TestScrapeFeed(t *testing.T) {
+	store := newFakeStore()
+	feed := database.Feed{
+		ID:              uuid.New(),
+		Url:             "https://example.com/feed.xml",
+		Kind:            ingest.FeedKindRSS,
+		FetchErrorCount: 2,
+		ParseWarning:    sql.NullString{String: "repaired", Valid: true},
+	}
+	fetcher := fakeFetcher{feed.Url: `<rss><channel><item><title>One</title></item><item><title>Two</title></item></channel></rss>`}
+	scrape(store, fetcher, nil, feed)
+	assert.Equal(t, []uuid.UUID{feed.ID}, store.fetched)
+	assert.Equal(t, []uuid.UUID{feed.ID}, store.cleared)
+	assert.Empty(t, store.fetchErrors)
+	assert.Equal(t, sql.NullString{}, store.warnings[feed.ID])
+
+	broken := database.Feed{ID: uuid.New(), Url: "https://example.com/gone.xml", Kind: ingest.FeedKindRSS}
+	scrape(store, fetcher, nil, broken)
+	assert.Contains(t, store.fetchErrors[broken.ID], "not found")
+	assert.NotContains(t, store.cleared, broken.ID)
+}
+
+func TestScrapeFeedSubscribesWebSub(t *testing.T) {
+	secrets := make(chan string, 1)
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secrets <- r.FormValue("hub.secret")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+	client := fetch.New(fetch.Config{Timeout: 5 * time.Second, MaxBodySize: 1 << 20, AllowPrivateNetworks: true})
+	subscriber := websub.NewSubscriber(websub.Config{CallbackURL: "https://reader.example.com/websub", LeaseSeconds: 3600}, client)
+
+	store := newFakeStore()
+	feed := database.Feed{ID: uuid.New(), Url: "https://example.com/feed.xml", Kind: ingest.FeedKindRSS}
+	fetcher := fakeFetcher{feed.Url: fmt.Sprintf(`<rss xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+		<atom:link rel="hub" href="%s"/><atom:link rel="self" href="%s"/>
+	</channel></rss>`, hub.URL, feed.Url)}
+
+	// A request the hub never verified is made again with the same secret.
+	store.subscriptions[feed.ID] = database.WebsubSubscription{
+		FeedID:      feed.ID,
+		Hub:         hub.URL,
+		Topic:       feed.Url,
+		Secret:      "kept",
+		State:       "pending",
+		RequestedAt: time.Now().UTC().Add(-2 * webSubPendingRetry),
+	}
+	scrape(store, fetcher, subscriber, feed)
+	assert.Equal(t, "kept", <-secrets)
+	assert.Equal(t, "kept", store.subscriptions[feed.ID].Secret)
+
+	// Until then, the hub isn't asked again.
+	scrape(store, fetcher, subscriber, feed)
+	assert.Empty(t, secrets)
+
+	// Moving to another hub takes a new secret.
+	store.subscriptions[feed.ID] = database.WebsubSubscription{
+		FeedID:      feed.ID,
+		Hub:         "https://old-hub.example.com/",
+		Topic:       feed.Url,
+		Secret:      "old",
+		State:       "active",
+		RequestedAt: time.Now().UTC(),
+	}
+	scrape(store, fetcher, subscriber, feed)
+	secret := <-secrets
+	assert.NotEqual(t, "old", secret)
+	assert.Equal(t, secret, store.subscriptions[feed.ID].Secret)
+}
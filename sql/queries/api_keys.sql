@@ -0,0 +1,34 @@
+-- name: CreateApiKey :one
+INSERT INTO api_keys (id, created_at, updated_at, user_id, name, prefix, key_hash, fever_key_hash, scopes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: UseApiKey :one
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE key_hash = $1
+AND revoked_at IS NULL
+AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING *;
+
+-- name: GetActiveApiKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at;
+
+-- name: ExpireApiKey :one
+UPDATE api_keys
+SET expires_at = LEAST(COALESCE(expires_at, @expires_at::TIMESTAMP), @expires_at::TIMESTAMP),
+updated_at = NOW()
+WHERE id = @id AND user_id = @user_id
+RETURNING *;
+
+-- name: RevokeApiKey :one
+UPDATE api_keys
+SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+AND revoked_at IS NULL
+RETURNING *;
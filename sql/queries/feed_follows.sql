@@ -6,3 +6,25 @@ RETURNING *;
 SELECT * FROM feed_follows WHERE user_id=$1;
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows WHERE id=$1 AND user_id=$2;
+-- name: DeleteFeedFollowByFeedShortID :exec
+DELETE FROM feed_follows
+USING feeds
+WHERE feeds.id = feed_follows.feed_id
+AND feed_follows.user_id = $1
+AND feeds.short_id = $2;
+-- name: SetFeedFollowExtractFullContent :one
+UPDATE feed_follows
+SET extract_full_content = $1,
+updated_at = NOW()
+WHERE id = $2 AND user_id = $3
+RETURNING *;
+-- name: FeedWantsFullContent :one
+SELECT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_id = $1 AND extract_full_content
+)::bool AS wants_full_content;
+-- name: UserWantsFullContent :one
+SELECT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_id = $1 AND user_id = $2 AND extract_full_content
+)::bool AS wants_full_content;
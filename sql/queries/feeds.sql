@@ -2,10 +2,22 @@
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
+-- name: CreateScrapedFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, kind, scrape_config)
+VALUES ($1, $2, $3, $4, $5, $6, 'scraped', $7)
+RETURNING *;
 -- name: GetFeeds :many
 SELECT * FROM feeds;
 -- name: GetNextFeedsToFetch :many
 SELECT * FROM feeds
+-- Feeds their hub pushes need no polling, unless the hub went silent.
+WHERE NOT EXISTS (
+    SELECT 1 FROM websub_subscriptions
+    WHERE websub_subscriptions.feed_id = feeds.id
+    AND websub_subscriptions.state = 'active'
+    AND websub_subscriptions.lease_expires_at > NOW()
+    AND COALESCE(websub_subscriptions.last_push_at, websub_subscriptions.verified_at) > NOW() - INTERVAL '1 day'
+)
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT $1;
 
@@ -15,3 +27,65 @@ SET last_fetched_at = NOW(),
 updated_at = NOW()
 WHERE id = $1
 RETURNING *;
+
+-- name: GetUserFollowedFeeds :many
+SELECT feeds.* FROM feeds
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.short_id;
+
+-- name: GetFeed :one
+SELECT * FROM feeds WHERE id = $1;
+
+-- name: GetFeedByUrl :one
+SELECT * FROM feeds WHERE url = $1;
+
+-- name: RecordFeedFetchError :exec
+UPDATE feeds
+SET last_fetch_error = $2,
+fetch_error_count = fetch_error_count + 1
+WHERE id = $1;
+
+-- name: ClearFeedFetchError :exec
+UPDATE feeds
+SET last_fetch_error = NULL,
+fetch_error_count = 0
+WHERE id = $1;
+
+-- name: GetFeedsWithHealth :many
+SELECT feeds.*,
+(SELECT COUNT(*) FROM feed_follows WHERE feed_follows.feed_id = feeds.id) AS follower_count,
+(SELECT COUNT(*) FROM posts WHERE posts.feed_id = feeds.id) AS post_count
+FROM feeds
+WHERE (NOT @only_failing::bool OR feeds.fetch_error_count > 0)
+ORDER BY feeds.fetch_error_count DESC, feeds.short_id;
+
+-- name: RefreshFeed :one
+UPDATE feeds
+SET last_fetched_at = NULL,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteFeed :one
+DELETE FROM feeds WHERE id = $1
+RETURNING *;
+
+-- name: GetUserCreatedFeeds :many
+SELECT * FROM feeds
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteUnfollowedUserFeeds :exec
+DELETE FROM feeds
+WHERE user_id = @user_id
+AND NOT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id <> @user_id
+);
+
+-- name: SetFeedParseWarning :exec
+UPDATE feeds
+SET parse_warning = $2
+WHERE id = $1;
@@ -0,0 +1,17 @@
+-- name: CreateGReaderToken :exec
+INSERT INTO greader_tokens (token_hash, api_key_id, created_at, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: UseGReaderToken :one
+UPDATE api_keys
+SET last_used_at = NOW()
+FROM greader_tokens
+WHERE greader_tokens.api_key_id = api_keys.id
+AND greader_tokens.token_hash = $1
+AND greader_tokens.expires_at > NOW()
+AND api_keys.revoked_at IS NULL
+AND (api_keys.expires_at IS NULL OR api_keys.expires_at > NOW())
+RETURNING api_keys.*;
+
+-- name: DeleteExpiredGReaderTokens :exec
+DELETE FROM greader_tokens WHERE expires_at < NOW();
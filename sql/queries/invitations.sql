@@ -0,0 +1,19 @@
+-- name: CreateInvitation :one
+INSERT INTO invitations (id, created_at, created_by, code_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetInvitations :many
+SELECT * FROM invitations ORDER BY created_at DESC;
+
+-- name: UseInvitation :one
+UPDATE invitations
+SET used_at = NOW(), used_by = $2
+WHERE code_hash = $1
+AND used_at IS NULL
+AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING *;
+
+-- name: DeleteInvitation :one
+DELETE FROM invitations WHERE id = $1
+RETURNING *;
@@ -0,0 +1,9 @@
+-- name: CreatePostAttachment :exec
+INSERT INTO post_attachments (id, created_at, post_id, kind, url, mime_type, length, duration_seconds, width, height)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (post_id, kind, url) DO NOTHING;
+
+-- name: GetPostsAttachments :many
+SELECT * FROM post_attachments
+WHERE post_id = ANY(@post_ids::uuid[])
+ORDER BY post_id, created_at, kind;
@@ -0,0 +1,9 @@
+-- name: CreatePostCategory :exec
+INSERT INTO post_categories (post_id, name)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetPostsCategories :many
+SELECT * FROM post_categories
+WHERE post_id = ANY(@post_ids::uuid[])
+ORDER BY post_id, name;
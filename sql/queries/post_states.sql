@@ -0,0 +1,50 @@
+-- name: GetUserUnreadPostShortIDs :many
+SELECT posts.short_id FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND post_states.is_read IS NOT TRUE
+ORDER BY posts.short_id;
+
+-- name: GetUserStarredPostShortIDs :many
+SELECT posts.short_id FROM posts
+JOIN post_states ON post_states.post_id = posts.id
+WHERE post_states.user_id = $1
+AND post_states.is_starred
+ORDER BY posts.short_id;
+
+-- name: SetPostRead :exec
+INSERT INTO post_states (user_id, post_id, is_read, created_at, updated_at)
+SELECT feed_follows.user_id, posts.id, @is_read::bool, NOW(), NOW() FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = @user_id AND posts.short_id = @short_id
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET is_read = EXCLUDED.is_read,
+updated_at = NOW();
+
+-- name: SetPostStarred :exec
+INSERT INTO post_states (user_id, post_id, is_starred, created_at, updated_at)
+SELECT feed_follows.user_id, posts.id, @is_starred::bool, NOW(), NOW() FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = @user_id AND posts.short_id = @short_id
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET is_starred = EXCLUDED.is_starred,
+updated_at = NOW();
+
+-- name: MarkPostsRead :exec
+INSERT INTO post_states (user_id, post_id, is_read, created_at, updated_at)
+SELECT feed_follows.user_id, posts.id, true, NOW(), NOW() FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = @user_id
+AND (@feed_short_id::bigint = 0 OR feeds.short_id = @feed_short_id)
+AND posts.created_at <= @before
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET is_read = true,
+updated_at = NOW();
+
+-- name: GetUserPostStates :many
+SELECT post_states.*, posts.url, posts.title FROM post_states
+JOIN posts ON posts.id = post_states.post_id
+WHERE post_states.user_id = $1
+ORDER BY post_states.created_at;
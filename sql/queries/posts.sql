@@ -1,26 +1,84 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, description, published_at, url, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO posts (id, created_at, updated_at, title, description, published_at, url, feed_id, episode, season, content, authors, description_text)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 RETURNING *;
 -- name: GetUserPosts :many
 SELECT posts.* FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
-WHERE feed_follows.user_id=$1
-ORDER BY posts.published_at
-LIMIT $2;
+WHERE feed_follows.user_id=@user_id
+AND (NOT @has_cursor::bool
+  OR (NOT @backward::bool AND (posts.published_at, posts.id) < (@cursor_published_at::TIMESTAMP, @cursor_id::uuid))
+  OR (@backward::bool AND (posts.published_at, posts.id) > (@cursor_published_at::TIMESTAMP, @cursor_id::uuid)))
+ORDER BY
+  CASE WHEN @backward::bool THEN posts.published_at END asc,
+  CASE WHEN @backward::bool THEN posts.id END asc,
+  posts.published_at desc,
+  posts.id desc
+LIMIT @row_limit;
 
--- name: FilterUserPosts :many
-SELECT posts.* FROM posts
+-- name: GetUserPostItems :many
+SELECT posts.*, feeds.short_id AS feed_short_id,
+COALESCE(post_states.is_read, false)::bool AS is_read,
+COALESCE(post_states.is_starred, false)::bool AS is_starred
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = @user_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = @user_id
+WHERE (@since_id::bigint = 0 OR posts.short_id > @since_id)
+AND (@max_id::bigint = 0 OR posts.short_id < @max_id)
+AND (cardinality(@short_ids::bigint[]) = 0 OR posts.short_id = ANY(@short_ids::bigint[]))
+ORDER BY
+  CASE WHEN @max_id::bigint = 0 THEN posts.short_id END asc,
+  posts.short_id desc
+LIMIT @row_limit;
+
+-- name: CountUserPosts :one
+SELECT COUNT(*) FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
-WHERE feed_follows.user_id=$1
-AND (@title::text = '' OR posts.title ILIKE '%' || @title || '%')
-AND (@description::text = '' OR posts.description ILIKE '%' || @description || '%')
-AND (@before::TIMESTAMP = '0001-01-01' OR posts.published_at <= @before )
-AND (@after::TIMESTAMP = '0001-01-01' OR posts.published_at >= @after )
+WHERE feed_follows.user_id=$1;
+
+-- name: GetUserStreamItems :many
+SELECT posts.*, feeds.short_id AS feed_short_id, feeds.name AS feed_name, feeds.url AS feed_url,
+COALESCE(post_states.is_read, false)::bool AS is_read,
+COALESCE(post_states.is_starred, false)::bool AS is_starred
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = @user_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = @user_id
+WHERE (@feed_short_id::bigint = 0 OR feeds.short_id = @feed_short_id)
+AND (NOT @only_unread::bool OR post_states.is_read IS NOT TRUE)
+AND (NOT @only_read::bool OR post_states.is_read)
+AND (NOT @only_starred::bool OR post_states.is_starred)
+AND (@newer_than::TIMESTAMP = '0001-01-01' OR posts.published_at >= @newer_than)
+AND (@older_than::TIMESTAMP = '0001-01-01' OR posts.published_at <= @older_than)
+AND (@continuation::bigint = 0
+  OR (@oldest_first::bool AND posts.short_id > @continuation)
+  OR (NOT @oldest_first::bool AND posts.short_id < @continuation))
+AND (cardinality(@short_ids::bigint[]) = 0 OR posts.short_id = ANY(@short_ids::bigint[]))
 ORDER BY
-  CASE WHEN @title_asc::bool THEN posts.title END asc,
-  CASE WHEN @title_desc::bool THEN posts.title END desc,
-  CASE WHEN @description_desc::bool THEN posts.description END desc,
-  CASE WHEN @description_asc::bool THEN posts.description END asc
-LIMIT $2
-OFFSET $3;
+  CASE WHEN @oldest_first::bool THEN posts.short_id END asc,
+  posts.short_id desc
+LIMIT @row_limit;
+
+-- name: GetUserUnreadCounts :many
+SELECT feeds.short_id AS feed_short_id, COUNT(*) AS count, MAX(posts.published_at)::TIMESTAMP AS newest_published_at
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND post_states.is_read IS NOT TRUE
+GROUP BY feeds.short_id;
+
+-- name: GetUserPost :one
+SELECT posts.* FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE posts.id = $1 AND feed_follows.user_id = $2;
+
+-- name: SetPostExtractedContent :one
+UPDATE posts
+SET extracted_content = $1,
+extracted_at = NOW(),
+updated_at = NOW()
+WHERE id = $2
+RETURNING *;
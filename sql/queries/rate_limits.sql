@@ -0,0 +1,17 @@
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limits AS r (key, tokens, allowed, updated_at)
+VALUES (@key, @capacity::float8 - 1, TRUE, NOW())
+ON CONFLICT (key) DO UPDATE SET
+allowed = LEAST(@capacity::float8, r.tokens + EXTRACT(EPOCH FROM NOW() - r.updated_at)::float8 * @refill_rate::float8) >= 1,
+tokens = LEAST(@capacity::float8, r.tokens + EXTRACT(EPOCH FROM NOW() - r.updated_at)::float8 * @refill_rate::float8)
+    - CASE WHEN LEAST(@capacity::float8, r.tokens + EXTRACT(EPOCH FROM NOW() - r.updated_at)::float8 * @refill_rate::float8) >= 1 THEN 1 ELSE 0 END,
+updated_at = NOW()
+RETURNING tokens, allowed;
+
+-- name: GetRateLimitTokens :one
+SELECT LEAST(@capacity::float8, tokens + EXTRACT(EPOCH FROM NOW() - updated_at)::float8 * @refill_rate::float8)::float8 AS tokens
+FROM rate_limits
+WHERE key = @key;
+
+-- name: DeleteStaleRateLimits :exec
+DELETE FROM rate_limits WHERE updated_at < $1;
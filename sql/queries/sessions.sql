@@ -0,0 +1,22 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, user_id, token_hash, csrf_token, expires_at, user_agent, ip)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: UseSession :one
+UPDATE sessions
+SET last_used_at = NOW()
+WHERE token_hash = $1
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: GetUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1
+AND expires_at > NOW()
+ORDER BY created_at;
+
+-- name: DeleteSession :one
+DELETE FROM sessions
+WHERE id = $1 AND user_id = $2
+RETURNING *;
@@ -0,0 +1,11 @@
+-- name: CreateSignupChallenge :exec
+INSERT INTO signup_challenges (challenge, expires_at)
+VALUES ($1, $2);
+
+-- name: UseSignupChallenge :one
+DELETE FROM signup_challenges
+WHERE challenge = $1 AND expires_at > NOW()
+RETURNING challenge;
+
+-- name: DeleteExpiredSignupChallenges :exec
+DELETE FROM signup_challenges WHERE expires_at <= NOW();
@@ -0,0 +1,10 @@
+-- name: GetStats :one
+SELECT
+(SELECT COUNT(*) FROM users) AS users,
+(SELECT COUNT(*) FROM users WHERE disabled_at IS NOT NULL) AS disabled_users,
+(SELECT COUNT(*) FROM feeds) AS feeds,
+(SELECT COUNT(*) FROM feeds WHERE fetch_error_count > 0) AS failing_feeds,
+(SELECT COUNT(*) FROM feed_follows) AS feed_follows,
+(SELECT COUNT(*) FROM posts) AS posts,
+(SELECT COUNT(*) FROM api_keys WHERE revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())) AS active_api_keys,
+(SELECT COUNT(*) FROM sessions WHERE expires_at > NOW()) AS active_sessions;
@@ -1,7 +1,83 @@
 -- name: CreateUser :one
-    INSERT INTO users (id, created_at, updated_at, name,  api_key)
-    VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea),'hex'))
+    INSERT INTO users (id, created_at, updated_at, name, feed_token)
+    VALUES ($1, $2, $3, $4, $5)
     RETURNING *;
-    
--- name: GetUserByApikey :one
-    SELECT * FROM users WHERE api_key =$1;
+
+-- name: GetUserByID :one
+    SELECT * FROM users WHERE id =$1;
+
+-- name: GetUserByFeedToken :one
+    SELECT * FROM users WHERE feed_token =$1 AND disabled_at IS NULL;
+
+-- name: RegenerateFeedToken :one
+    UPDATE users
+    SET feed_token = $2,
+    updated_at = NOW()
+    WHERE id = $1
+    RETURNING *;
+
+-- name: GetUserByFeverKeyHash :one
+    SELECT users.* FROM users
+    JOIN api_keys ON api_keys.user_id = users.id
+    WHERE api_keys.fever_key_hash =$1
+    AND users.disabled_at IS NULL
+    AND api_keys.revoked_at IS NULL
+    AND (api_keys.expires_at IS NULL OR api_keys.expires_at > NOW());
+
+-- name: GetUserByNameWithPassword :one
+    SELECT * FROM users WHERE name =$1 AND password_hash IS NOT NULL;
+
+-- name: SetUserPassword :one
+    UPDATE users
+    SET password_hash = $1,
+    updated_at = NOW()
+    WHERE id = $2
+    RETURNING *;
+
+-- name: CreateOIDCUser :one
+    INSERT INTO users (id, created_at, updated_at, name, email, oidc_issuer, oidc_subject, feed_token)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    RETURNING *;
+
+-- name: GetUserByOIDCSubject :one
+    SELECT * FROM users WHERE oidc_issuer =$1 AND oidc_subject =$2;
+
+-- name: LinkUserOIDC :one
+    UPDATE users
+    SET oidc_issuer = $1,
+    oidc_subject = $2,
+    email = $3,
+    updated_at = NOW()
+    WHERE id = $4
+    RETURNING *;
+
+-- name: ListUsers :many
+    SELECT * FROM users ORDER BY created_at, id;
+
+-- name: SetUserDisabled :one
+    UPDATE users
+    SET disabled_at = CASE WHEN @disabled::bool THEN COALESCE(disabled_at, NOW()) END,
+    updated_at = NOW()
+    WHERE id = @id
+    RETURNING *;
+
+-- name: SetUserAdmin :one
+    UPDATE users
+    SET is_admin = $1,
+    updated_at = NOW()
+    WHERE id = $2
+    RETURNING *;
+
+-- name: DeleteUser :one
+    DELETE FROM users WHERE id = $1
+    RETURNING *;
+
+-- name: UpdateUserName :one
+    UPDATE users
+    SET name = $1,
+    updated_at = NOW()
+    WHERE id = $2
+    RETURNING *;
+
+-- name: GetUserByName :one
+    SELECT * FROM users WHERE lower(name) = lower(@name);
@@ -0,0 +1,52 @@
+-- name: SubscribeWebSub :one
+INSERT INTO websub_subscriptions (feed_id, created_at, updated_at, hub, topic, secret, requested_at)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, NOW())
+ON CONFLICT (feed_id) DO UPDATE
+SET updated_at = NOW(),
+hub = EXCLUDED.hub,
+topic = EXCLUDED.topic,
+secret = EXCLUDED.secret,
+state = 'pending',
+requested_at = NOW(),
+verified_at = NULL,
+lease_expires_at = NULL
+RETURNING *;
+
+-- name: GetWebSubSubscription :one
+SELECT * FROM websub_subscriptions WHERE feed_id = $1;
+
+-- name: ActivateWebSubSubscription :one
+UPDATE websub_subscriptions
+SET state = 'active',
+verified_at = NOW(),
+lease_expires_at = $2,
+updated_at = NOW()
+WHERE feed_id = $1
+RETURNING *;
+
+-- name: DenyWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = 'denied',
+lease_expires_at = NULL,
+updated_at = NOW()
+WHERE feed_id = $1;
+
+-- name: RecordWebSubPush :exec
+UPDATE websub_subscriptions
+SET last_push_at = NOW()
+WHERE feed_id = $1;
+
+-- name: GetWebSubSubscriptionsToRenew :many
+SELECT * FROM websub_subscriptions
+WHERE state = 'active'
+AND lease_expires_at < NOW() + INTERVAL '1 day'
+AND requested_at < NOW() - INTERVAL '1 hour'
+ORDER BY lease_expires_at;
+
+-- name: MarkWebSubRenewalRequested :exec
+UPDATE websub_subscriptions
+SET requested_at = NOW()
+WHERE feed_id = $1;
+
+-- name: DeleteWebSubSubscription :exec
+DELETE FROM websub_subscriptions WHERE feed_id = $1;
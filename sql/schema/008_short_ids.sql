@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN short_id BIGSERIAL UNIQUE;
+ALTER TABLE posts ADD COLUMN short_id BIGSERIAL UNIQUE;
+-- +goose Down
+ALTER TABLE posts DROP COLUMN short_id;
+ALTER TABLE feeds DROP COLUMN short_id;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    fever_key_hash VARCHAR(64) UNIQUE NOT NULL,
+    last_used_at TIMESTAMP
+);
+INSERT INTO api_keys (id, created_at, updated_at, user_id, name, prefix, key_hash, fever_key_hash)
+SELECT md5(random()::text || id::text)::uuid, NOW(), NOW(), id, 'default', left(api_key, 8),
+    encode(sha256(api_key::bytea), 'hex'),
+    encode(sha256(md5(name || ':' || api_key)::bytea), 'hex')
+FROM users;
+ALTER TABLE users DROP COLUMN api_key;
+-- +goose Down
+ALTER TABLE users ADD COLUMN api_key VARCHAR(64) UNIQUE NOT NULL DEFAULT (
+    encode(sha256(random()::text::bytea),'hex')
+);
+DROP TABLE api_keys;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE api_keys ADD COLUMN expires_at TIMESTAMP;
+ALTER TABLE api_keys ADD COLUMN revoked_at TIMESTAMP;
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN revoked_at;
+ALTER TABLE api_keys DROP COLUMN expires_at;
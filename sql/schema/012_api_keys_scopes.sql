@@ -0,0 +1,4 @@
+-- +goose Up
+ALTER TABLE api_keys ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{admin}';
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN scopes;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email TEXT;
+ALTER TABLE users ADD COLUMN oidc_issuer TEXT;
+ALTER TABLE users ADD COLUMN oidc_subject TEXT;
+CREATE UNIQUE INDEX users_email_idx ON users (lower(email));
+CREATE UNIQUE INDEX users_oidc_subject_idx ON users (oidc_issuer, oidc_subject);
+-- +goose Down
+DROP INDEX users_oidc_subject_idx;
+DROP INDEX users_email_idx;
+ALTER TABLE users DROP COLUMN oidc_subject;
+ALTER TABLE users DROP COLUMN oidc_issuer;
+ALTER TABLE users DROP COLUMN email;
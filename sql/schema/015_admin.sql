@@ -0,0 +1,12 @@
+-- +goose Up
+-- The first admin has to be promoted by hand:
+-- UPDATE users SET is_admin = TRUE WHERE name = '...';
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
+ALTER TABLE feeds ADD COLUMN last_fetch_error TEXT;
+ALTER TABLE feeds ADD COLUMN fetch_error_count INT NOT NULL DEFAULT 0;
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN fetch_error_count;
+ALTER TABLE feeds DROP COLUMN last_fetch_error;
+ALTER TABLE users DROP COLUMN disabled_at;
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Feeds outlive the user who created them, as others may follow them.
+ALTER TABLE feeds ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE feeds DROP CONSTRAINT feeds_user_id_fkey;
+ALTER TABLE feeds ADD CONSTRAINT feeds_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+-- +goose Down
+DELETE FROM feeds WHERE user_id IS NULL;
+ALTER TABLE feeds DROP CONSTRAINT feeds_user_id_fkey;
+ALTER TABLE feeds ADD CONSTRAINT feeds_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE feeds ALTER COLUMN user_id SET NOT NULL;
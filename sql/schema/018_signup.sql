@@ -0,0 +1,26 @@
+-- +goose Up
+-- Names become unique regardless of case, later duplicates get a suffix.
+UPDATE users SET name = users.name || '-' || left(users.id::text, 8)
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY lower(name) ORDER BY created_at, id) AS rank
+    FROM users
+) ranked
+WHERE ranked.id = users.id AND ranked.rank > 1;
+CREATE UNIQUE INDEX users_name_key ON users (lower(name));
+CREATE TABLE invitations (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    code_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP,
+    used_at TIMESTAMP,
+    used_by UUID REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE TABLE signup_challenges (
+    challenge TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+-- +goose Down
+DROP TABLE signup_challenges;
+DROP TABLE invitations;
+DROP INDEX users_name_key;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN episode INTEGER;
+ALTER TABLE posts ADD COLUMN season INTEGER;
+CREATE TABLE post_attachments (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    url TEXT NOT NULL,
+    mime_type TEXT,
+    length BIGINT,
+    duration_seconds INTEGER,
+    width INTEGER,
+    height INTEGER,
+    UNIQUE (post_id, kind, url)
+);
+-- +goose Down
+DROP TABLE post_attachments;
+ALTER TABLE posts DROP COLUMN season;
+ALTER TABLE posts DROP COLUMN episode;
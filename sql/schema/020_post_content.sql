@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content TEXT;
+ALTER TABLE posts ADD COLUMN authors TEXT[] NOT NULL DEFAULT '{}';
+CREATE TABLE post_categories (
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    PRIMARY KEY (post_id, name)
+);
+CREATE INDEX post_categories_name_idx ON post_categories (lower(name));
+-- +goose Down
+DROP TABLE post_categories;
+ALTER TABLE posts DROP COLUMN authors;
+ALTER TABLE posts DROP COLUMN content;
@@ -0,0 +1,5 @@
+-- +goose Up
+-- Posts stored before descriptions were sanitized have no description_text.
+ALTER TABLE posts ADD COLUMN description_text TEXT;
+-- +goose Down
+ALTER TABLE posts DROP COLUMN description_text;
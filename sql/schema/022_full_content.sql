@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE feed_follows ADD COLUMN extract_full_content BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts ADD COLUMN extracted_content TEXT;
+ALTER TABLE posts ADD COLUMN extracted_at TIMESTAMP;
+-- +goose Down
+ALTER TABLE posts DROP COLUMN extracted_at;
+ALTER TABLE posts DROP COLUMN extracted_content;
+ALTER TABLE feed_follows DROP COLUMN extract_full_content;
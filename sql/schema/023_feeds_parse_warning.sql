@@ -0,0 +1,4 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN parse_warning TEXT;
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN parse_warning;
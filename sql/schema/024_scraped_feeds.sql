@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN kind TEXT NOT NULL DEFAULT 'rss' CHECK (kind IN ('rss', 'scraped'));
+ALTER TABLE feeds ADD COLUMN scrape_config JSONB NOT NULL DEFAULT '{}';
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN scrape_config;
+ALTER TABLE feeds DROP COLUMN kind;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE websub_subscriptions (
+    feed_id UUID PRIMARY KEY REFERENCES feeds(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    hub TEXT NOT NULL,
+    topic TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    state TEXT NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'active', 'denied')),
+    requested_at TIMESTAMP NOT NULL,
+    verified_at TIMESTAMP,
+    lease_expires_at TIMESTAMP,
+    last_push_at TIMESTAMP
+);
+-- +goose Down
+DROP TABLE websub_subscriptions;
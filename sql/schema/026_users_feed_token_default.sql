@@ -0,0 +1,8 @@
+-- +goose Up
+-- Feed tokens are generated with crypto/rand by the server, random() is not
+-- fit for secrets.
+ALTER TABLE users ALTER COLUMN feed_token DROP DEFAULT;
+-- +goose Down
+ALTER TABLE users ALTER COLUMN feed_token SET DEFAULT (
+    encode(sha256(random()::text::bytea),'hex')
+);
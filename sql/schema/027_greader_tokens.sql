@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE greader_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    api_key_id UUID NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+-- +goose Down
+DROP TABLE greader_tokens;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Tokens created before 026 came from random(), which is predictable. Replace
+-- them with ones from a cryptographic generator, as the server now makes;
+-- timeline URLs handed out before stop working.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+UPDATE users SET feed_token = encode(gen_random_bytes(32), 'hex');
+-- +goose Down
+-- The old tokens are gone, and the new ones are as good as any.
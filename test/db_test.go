@@ -1,9 +1,16 @@
 package test
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,7 +24,11 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/leguzman/rss-project/handlers"
+	"github.com/leguzman/rss-project/internal/auth"
 	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/fetch"
+	"github.com/leguzman/rss-project/internal/ratelimit"
+	"github.com/leguzman/rss-project/internal/websub"
 	"github.com/leguzman/rss-project/models"
 	"github.com/leguzman/rss-project/routes"
 	_ "github.com/lib/pq"
@@ -33,6 +44,16 @@ var apiKey string
 var	feed models.Feed
 var result handlers.WrappedSlice[models.FeedFollow]
 
+// localFetch reaches the feeds tests serve on loopback, which the server
+// refuses to fetch by default.
+var localFetch = fetch.New(fetch.Config{
+	UserAgent:            "rss-project tests",
+	Timeout:              10 * time.Second,
+	MaxBodySize:          10 << 20,
+	MaxRedirects:         5,
+	AllowPrivateNetworks: true,
+})
+
 func TestMain(m *testing.M) {
 
 	// uses a sensible default on windows (tcp/http) and linux/osx (socket)
@@ -112,7 +133,7 @@ func TestMain(m *testing.M) {
 
 func TestHealthAndRoot(t *testing.T) {
 	server = &http.Server{
-		Handler: routes.GetRouter(handlers.ApiConfig{DB: database.New(db)}),
+		Handler: routes.GetRouter(handlers.ApiConfig{DB: database.New(db), Conn: db}),
 	}
 	req, _ := http.NewRequest(http.MethodGet, "/", nil)
 	response := executeRequest(req, server)
@@ -132,14 +153,14 @@ func createUser(name string)(req *http.Request){
 func TestUserHandler(t *testing.T) {
 	queries := database.New(db)
 	server = &http.Server{
-		Handler: routes.GetRouter(handlers.ApiConfig{DB: queries}),
+		Handler: routes.GetRouter(handlers.ApiConfig{DB: queries, Conn: db, Fetch: localFetch}),
 	}
 	response := executeRequest(createUser("Luis"), server)
 
 	checkResponseCode(t, http.StatusCreated, response.Code)
 	assert.Contains(t, response.Body.String(), `"name":"Luis"`)
 
-	user := models.User{}
+	user := models.UserWithApiKey{}
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		log.Fatal("Couldn't read user!")
@@ -155,15 +176,30 @@ func TestUserHandler(t *testing.T) {
 
 	response = executeRequest(req, server)
 	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.NotContains(t, response.Body.String(), user.APIKey)
 
+	jsonBody := []byte(`{"name": "dashboard"}`)
+	req, _ = http.NewRequest(http.MethodPost, "/v1/api_keys", bytes.NewReader(jsonBody))
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	createdKey := models.CreatedApiKey{}
+	json.Unmarshal(response.Body.Bytes(), &createdKey)
+	assert.Equal(t, "dashboard", createdKey.Name)
+	assert.True(t, strings.HasPrefix(createdKey.Key, createdKey.Prefix))
 
+	req, _ = http.NewRequest(http.MethodGet, "/v1/users", nil)
+	req.Header.Add("Authorization", "ApiKey "+createdKey.Key)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"name":"Luis"`)
 }
 
 
 func TestFeedsHandler(t *testing.T){
 	queries := database.New(db)
     server:= &http.Server{
-        Handler: routes.GetRouter(handlers.ApiConfig{DB: queries}),
+        Handler: routes.GetRouter(handlers.ApiConfig{DB: queries, Conn: db}),
     }
     jsonBody := []byte(`
 	{
@@ -277,6 +313,1113 @@ func TestUserPosts(t *testing.T){
 }
 
 
+func TestTimeline(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/v1/users", nil)
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	user := models.User{}
+	json.Unmarshal(response.Body.Bytes(), &user)
+
+	for format, contentType := range map[string]string{
+		"rss":  "application/rss+xml",
+		"atom": "application/atom+xml",
+		"json": "application/feed+json",
+	} {
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/timeline/%s/%s", user.FeedToken, format), nil)
+		response = executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		assert.Contains(t, response.Header().Get("Content-Type"), contentType)
+		assert.Contains(t, response.Body.String(), "Test Post")
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/v1/timeline/not-a-token/rss", nil)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	// Entries are credited to their own authors, the feed to its owner.
+	// X-Forwarded-Proto is only believed with TRUST_PROXY_HEADERS.
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/timeline/%s/atom", user.FeedToken), nil)
+	req.Header.Add("X-Forwarded-Proto", "https")
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "\n  <author>\n    <name>Luis</name>\n  </author>\n")
+	assert.Contains(t, response.Body.String(), `href="http://`)
+
+	req, _ = http.NewRequest(http.MethodPost, "/v1/users/feed_token", nil)
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	regenerated := models.User{}
+	json.Unmarshal(response.Body.Bytes(), &regenerated)
+	assert.Len(t, regenerated.FeedToken, 64)
+	assert.NotEqual(t, user.FeedToken, regenerated.FeedToken)
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/timeline/%s/rss", user.FeedToken), nil)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, server).Code)
+}
+
+func TestFever(t *testing.T) {
+	feverKey := fmt.Sprintf("%x", md5.Sum([]byte("Luis:"+strings.TrimPrefix(apiKey, "ApiKey "))))
+
+	req, _ := http.NewRequest(http.MethodPost, "/fever/?api&feeds&items&unread_item_ids", strings.NewReader("api_key=wrong"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"auth":0`)
+
+	req, _ = http.NewRequest(http.MethodPost, "/fever/?api&feeds&items&unread_item_ids", strings.NewReader("api_key="+feverKey))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"auth":1`)
+	assert.Contains(t, response.Body.String(), "Test Post")
+
+	result := struct {
+		Items []struct {
+			ID int64 `json:"id"`
+		} `json:"items"`
+	}{}
+	json.Unmarshal(response.Body.Bytes(), &result)
+	if assert.NotEmpty(t, result.Items) {
+		form := fmt.Sprintf("api_key=%s&mark=item&as=saved&id=%d", feverKey, result.Items[0].ID)
+		req, _ = http.NewRequest(http.MethodPost, "/fever/?api&saved_item_ids", strings.NewReader(form))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		response = executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		assert.Contains(t, response.Body.String(), fmt.Sprintf(`"saved_item_ids":"%d"`, result.Items[0].ID))
+	}
+
+	// Feed 0 isn't everything, only group 0 is.
+	for _, id := range []string{"0", "-1"} {
+		form := fmt.Sprintf("api_key=%s&mark=feed&as=read&id=%s", feverKey, id)
+		req, _ = http.NewRequest(http.MethodPost, "/fever/?api&unread_item_ids", strings.NewReader(form))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, server).Code)
+	}
+}
+
+func TestGReader(t *testing.T) {
+	form := "Email=Luis&Passwd=" + strings.TrimPrefix(apiKey, "ApiKey ")
+	req, _ := http.NewRequest(http.MethodPost, "/api/greader/accounts/ClientLogin", strings.NewReader(form))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	token := ""
+	for _, line := range strings.Split(response.Body.String(), "\n") {
+		if auth, found := strings.CutPrefix(line, "Auth="); found {
+			token = auth
+		}
+	}
+	assert.NotEmpty(t, token)
+	assert.NotEqual(t, strings.TrimPrefix(apiKey, "ApiKey "), token)
+
+	// The API key itself isn't a login token.
+	req, _ = http.NewRequest(http.MethodGet, "/api/greader/reader/api/0/user-info", nil)
+	req.Header.Add("Authorization", "GoogleLogin auth="+strings.TrimPrefix(apiKey, "ApiKey "))
+	checkResponseCode(t, http.StatusUnauthorized, executeRequest(req, server).Code)
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/greader/reader/api/0/subscription/list?output=json", nil)
+	req.Header.Add("Authorization", "GoogleLogin auth="+token)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), feed.Url)
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/greader/reader/api/0/stream/contents/user/-/state/com.google/reading-list?output=json", nil)
+	req.Header.Add("Authorization", "GoogleLogin auth="+token)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "Test Post")
+
+	stream := struct {
+		Items []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	}{}
+	json.Unmarshal(response.Body.Bytes(), &stream)
+	if assert.NotEmpty(t, stream.Items) {
+		form = "a=user/-/state/com.google/read&i=" + stream.Items[0].ID
+		req, _ = http.NewRequest(http.MethodPost, "/api/greader/reader/api/0/edit-tag", strings.NewReader(form))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Authorization", "GoogleLogin auth="+token)
+		response = executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		req, _ = http.NewRequest(http.MethodGet, "/api/greader/reader/api/0/stream/contents/user/-/state/com.google/read?output=json", nil)
+		req.Header.Add("Authorization", "GoogleLogin auth="+token)
+		response = executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		assert.Contains(t, response.Body.String(), stream.Items[0].ID)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/api/greader/reader/api/0/stream/items/contents", strings.NewReader(strings.Repeat("i=1&", 1001)))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Authorization", "GoogleLogin auth="+token)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req, server).Code)
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/greader/reader/api/0/user-info", nil)
+	req.Header.Add("Authorization", "GoogleLogin auth=wrong")
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestPostsPagination(t *testing.T) {
+	queries := database.New(db)
+	for i := 0; i < 3; i++ {
+		_, err := queries.CreatePost(context.Background(), database.CreatePostParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now().UTC(),
+			UpdatedAt:   time.Now().UTC(),
+			Title:       fmt.Sprintf("Paged Post %d", i),
+			PublishedAt: time.Now().UTC().Add(-time.Duration(i+1) * time.Hour),
+			Url:         fmt.Sprintf("paged link %d", i),
+			FeedID:      feed.ID,
+		})
+		if err != nil {
+			log.Fatal("Couldn't populate Db with posts!")
+		}
+	}
+
+	for _, path := range []string{"/v1/posts", "/v1/post"} {
+		page := handlers.WrappedSlice[models.Post]{}
+		req, _ := http.NewRequest(http.MethodGet, path+"?limit=2&count=true", nil)
+		req.Header.Add("Authorization", apiKey)
+		response := executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		json.Unmarshal(response.Body.Bytes(), &page)
+		assert.Equal(t, 2, page.Size)
+		assert.NotEmpty(t, page.NextCursor)
+		assert.Empty(t, page.PrevCursor)
+		if assert.NotNil(t, page.Total) {
+			assert.EqualValues(t, 4, *page.Total)
+		}
+		first := page.Results
+
+		req, _ = http.NewRequest(http.MethodGet, path+"?limit=2&cursor="+page.NextCursor, nil)
+		req.Header.Add("Authorization", apiKey)
+		response = executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		page = handlers.WrappedSlice[models.Post]{}
+		json.Unmarshal(response.Body.Bytes(), &page)
+		assert.Equal(t, 2, page.Size)
+		assert.Empty(t, page.NextCursor)
+		assert.NotEmpty(t, page.PrevCursor)
+		assert.NotEqual(t, first[0].ID, page.Results[0].ID)
+
+		req, _ = http.NewRequest(http.MethodGet, path+"?limit=2&cursor="+page.PrevCursor, nil)
+		req.Header.Add("Authorization", apiKey)
+		response = executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		page = handlers.WrappedSlice[models.Post]{}
+		json.Unmarshal(response.Body.Bytes(), &page)
+		assert.Equal(t, first, page.Results)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/v1/posts?cursor=garbage", nil)
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestFilterUserPostsSort(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/v1/post?sort=title,-published_at", nil)
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	page := handlers.WrappedSlice[models.Post]{}
+	json.Unmarshal(response.Body.Bytes(), &page)
+	for i := 1; i < len(page.Results); i++ {
+		assert.LessOrEqual(t, page.Results[i-1].Title, page.Results[i].Title)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/v1/post?sort=-relevance&q=paged", nil)
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "Paged Post")
+	assert.NotContains(t, response.Body.String(), "Test Post")
+
+	for _, sort := range []string{"bogus", "title,title", "--title", "relevance"} {
+		req, _ = http.NewRequest(http.MethodGet, "/v1/post?sort="+sort, nil)
+		req.Header.Add("Authorization", apiKey)
+		response = executeRequest(req, server)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+
+	// Cursor keys must have the type of the column they sort on.
+	for query, keys := range map[string]string{
+		"sort=-published_at":    `[1]`,
+		"sort=created_at":       `["yesterday"]`,
+		"sort=title":            `[{"a": 1}]`,
+		"sort=relevance&q=post": `["high"]`,
+	} {
+		sort, _, _ := strings.Cut(strings.TrimPrefix(query, "sort="), "&")
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(`{"s": "` + sort + `", "k": ` + keys + `, "id": "` + uuid.NewString() + `"}`))
+		req, _ = http.NewRequest(http.MethodGet, "/v1/post?"+query+"&cursor="+cursor, nil)
+		req.Header.Add("Authorization", apiKey)
+		response = executeRequest(req, server)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+		assert.Contains(t, response.Body.String(), "malformed cursor", query)
+	}
+}
+
+func TestApiKeyRotation(t *testing.T) {
+	createKey := func(name string) models.CreatedApiKey {
+		req, _ := http.NewRequest(http.MethodPost, "/v1/api_keys", strings.NewReader(`{"name": "`+name+`"}`))
+		req.Header.Add("Authorization", apiKey)
+		response := executeRequest(req, server)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+		key := models.CreatedApiKey{}
+		json.Unmarshal(response.Body.Bytes(), &key)
+		return key
+	}
+	getUser := func(key string) int {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/users", nil)
+		req.Header.Add("Authorization", "ApiKey "+key)
+		return executeRequest(req, server).Code
+	}
+
+	old := createKey("rotating")
+	req, _ := http.NewRequest(http.MethodPost, "/v1/api_keys/rotate", strings.NewReader(`{"grace_period_seconds": 0}`))
+	req.Header.Add("Authorization", "ApiKey "+old.Key)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	rotated := models.CreatedApiKey{}
+	json.Unmarshal(response.Body.Bytes(), &rotated)
+	assert.Equal(t, "rotating", rotated.Name)
+	checkResponseCode(t, http.StatusOK, getUser(rotated.Key))
+	checkResponseCode(t, http.StatusBadRequest, getUser(old.Key))
+
+	graced := createKey("graced")
+	req, _ = http.NewRequest(http.MethodPost, "/v1/api_keys/rotate", strings.NewReader(`{"grace_period_seconds": 3600}`))
+	req.Header.Add("Authorization", "ApiKey "+graced.Key)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	checkResponseCode(t, http.StatusOK, getUser(graced.Key))
+
+	req, _ = http.NewRequest(http.MethodDelete, "/v1/api_keys/"+rotated.ID.String(), nil)
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusNoContent, response.Code)
+	checkResponseCode(t, http.StatusBadRequest, getUser(rotated.Key))
+
+	req, _ = http.NewRequest(http.MethodGet, "/v1/api_keys", nil)
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.NotContains(t, response.Body.String(), rotated.ID.String())
+	assert.Contains(t, response.Body.String(), graced.ID.String())
+}
+
+func TestApiKeyScopes(t *testing.T) {
+	createKey := func(auth, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/v1/api_keys", strings.NewReader(body))
+		req.Header.Add("Authorization", auth)
+		return executeRequest(req, server)
+	}
+
+	response := createKey(apiKey, `{"name": "dashboard"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	readOnly := models.CreatedApiKey{}
+	json.Unmarshal(response.Body.Bytes(), &readOnly)
+	assert.Equal(t, []string{"read"}, readOnly.Scopes)
+
+	req, _ := http.NewRequest(http.MethodGet, "/v1/posts", nil)
+	req.Header.Add("Authorization", "ApiKey "+readOnly.Key)
+	checkResponseCode(t, http.StatusOK, executeRequest(req, server).Code)
+
+	req, _ = http.NewRequest(http.MethodPost, "/v1/feeds", strings.NewReader(`{"name": "Scoped", "url": "https://example.com/scoped.xml"}`))
+	req.Header.Add("Authorization", "ApiKey "+readOnly.Key)
+	checkResponseCode(t, http.StatusForbidden, executeRequest(req, server).Code)
+
+	response = createKey("ApiKey "+readOnly.Key, `{"name": "escalated", "scopes": ["feeds"]}`)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	response = createKey(apiKey, `{"name": "bogus", "scopes": ["write"]}`)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestSessions(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "/v1/users/password", strings.NewReader(`{"password": "correct horse"}`))
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusNoContent, response.Code)
+
+	user := models.User{}
+	req, _ = http.NewRequest(http.MethodGet, "/v1/users", nil)
+	req.Header.Add("Authorization", apiKey)
+	json.Unmarshal(executeRequest(req, server).Body.Bytes(), &user)
+
+	req, _ = http.NewRequest(http.MethodPost, "/v1/login", strings.NewReader(`{"name": "`+user.Name+`", "password": "wrong horse"}`))
+	checkResponseCode(t, http.StatusUnauthorized, executeRequest(req, server).Code)
+
+	req, _ = http.NewRequest(http.MethodPost, "/v1/login", strings.NewReader(`{"name": "`+user.Name+`", "password": "correct horse"}`))
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	session := models.CreatedSession{}
+	json.Unmarshal(response.Body.Bytes(), &session)
+	var sessionCookie *http.Cookie
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == "session" {
+			sessionCookie = cookie
+		}
+	}
+	if assert.NotNil(t, sessionCookie) {
+		assert.True(t, sessionCookie.HttpOnly)
+		// Plain HTTP, as when running locally.
+		assert.False(t, sessionCookie.Secure)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/v1/login", strings.NewReader(`{"name": "`+user.Name+`", "password": "correct horse"}`))
+	req.TLS = &tls.ConnectionState{}
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	for _, cookie := range response.Result().Cookies() {
+		assert.True(t, cookie.Secure, cookie.Name)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/v1/sessions", nil)
+	req.AddCookie(sessionCookie)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), session.ID.String())
+
+	// Linking an identity changes state, so it's a POST that needs the CSRF
+	// token like any other. Single sign-on isn't configured here.
+	req, _ = http.NewRequest(http.MethodGet, "/v1/auth/oidc/link", nil)
+	req.AddCookie(sessionCookie)
+	checkResponseCode(t, http.StatusMethodNotAllowed, executeRequest(req, server).Code)
+	req, _ = http.NewRequest(http.MethodPost, "/v1/auth/oidc/link", nil)
+	req.AddCookie(sessionCookie)
+	checkResponseCode(t, http.StatusForbidden, executeRequest(req, server).Code)
+	req, _ = http.NewRequest(http.MethodPost, "/v1/auth/oidc/link", nil)
+	req.AddCookie(sessionCookie)
+	req.Header.Add("X-CSRF-Token", session.CSRFToken)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, server).Code)
+
+	logout := func(csrfToken string) int {
+		req, _ := http.NewRequest(http.MethodPost, "/v1/logout", nil)
+		req.AddCookie(sessionCookie)
+		if csrfToken != "" {
+			req.Header.Add("X-CSRF-Token", csrfToken)
+		}
+		return executeRequest(req, server).Code
+	}
+	checkResponseCode(t, http.StatusForbidden, logout(""))
+	checkResponseCode(t, http.StatusNoContent, logout(session.CSRFToken))
+	checkResponseCode(t, http.StatusUnauthorized, logout(session.CSRFToken))
+}
+
+func TestAdmin(t *testing.T) {
+	adminRequest := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Add("Authorization", apiKey)
+		return executeRequest(req, server)
+	}
+	checkResponseCode(t, http.StatusForbidden, adminRequest(http.MethodGet, "/v1/admin/stats", "").Code)
+
+	_, err := db.Exec("UPDATE users SET is_admin = TRUE WHERE name = 'Luis'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := adminRequest(http.MethodGet, "/v1/admin/stats", "")
+	checkResponseCode(t, http.StatusOK, response.Code)
+	stats := models.Stats{}
+	json.Unmarshal(response.Body.Bytes(), &stats)
+	assert.NotZero(t, stats.Users)
+	assert.NotZero(t, stats.Feeds)
+
+	response = executeRequest(createUser("Spammer"), server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	spammer := models.UserWithApiKey{}
+	json.Unmarshal(response.Body.Bytes(), &spammer)
+
+	response = adminRequest(http.MethodPatch, "/v1/admin/users/"+spammer.ID.String(), `{"disabled": true}`)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	req, _ := http.NewRequest(http.MethodGet, "/v1/users", nil)
+	req.Header.Add("Authorization", "ApiKey "+spammer.APIKey)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req, server).Code)
+
+	response = adminRequest(http.MethodGet, "/v1/admin/users", "")
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), spammer.ID.String())
+	assert.NotContains(t, response.Body.String(), "feed_token")
+
+	response = adminRequest(http.MethodGet, "/v1/admin/feeds", "")
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), feed.ID.String())
+	checkResponseCode(t, http.StatusAccepted, adminRequest(http.MethodPost, "/v1/admin/feeds/"+feed.ID.String()+"/refresh", "").Code)
+
+	checkResponseCode(t, http.StatusNoContent, adminRequest(http.MethodDelete, "/v1/admin/users/"+spammer.ID.String(), "").Code)
+	checkResponseCode(t, http.StatusNotFound, adminRequest(http.MethodDelete, "/v1/admin/users/"+spammer.ID.String(), "").Code)
+}
+
+func TestRateLimit(t *testing.T) {
+	queries := database.New(db)
+	limiter := ratelimit.NewLimiter(ratelimit.NewPostgresStore(queries), map[string]ratelimit.Limit{
+		"read": {Requests: 2, Period: time.Hour},
+		"auth": {Requests: 2, Period: time.Hour},
+	})
+	limited := &http.Server{
+		Handler: routes.GetRouter(handlers.ApiConfig{DB: queries, Conn: db, RateLimiter: limiter}),
+	}
+	getUser := func(key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/users", nil)
+		req.Header.Add("Authorization", key)
+		return executeRequest(req, limited)
+	}
+	response := getUser(apiKey)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Equal(t, "2", response.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", response.Header().Get("RateLimit-Remaining"))
+	checkResponseCode(t, http.StatusOK, getUser(apiKey).Code)
+	response = getUser(apiKey)
+	checkResponseCode(t, http.StatusTooManyRequests, response.Code)
+	assert.NotEmpty(t, response.Header().Get("Retry-After"))
+
+	// Only reads are limited here.
+	req, _ := http.NewRequest(http.MethodPost, "/v1/api_keys", strings.NewReader(`{"name": "limited"}`))
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, limited)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	other := models.CreatedApiKey{}
+	json.Unmarshal(response.Body.Bytes(), &other)
+
+	// Keys sharing an IP have their own budget.
+	checkResponseCode(t, http.StatusOK, getUser("ApiKey "+other.Key).Code)
+	checkResponseCode(t, http.StatusOK, getUser("ApiKey "+other.Key).Code)
+	checkResponseCode(t, http.StatusTooManyRequests, getUser("ApiKey "+other.Key).Code)
+
+	// Failed authentications are counted per IP, which is turned away once
+	// out of them, before its credentials are checked.
+	fromGuesser := func(key string) int {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/users", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.Header.Add("Authorization", key)
+		return executeRequest(req, limited).Code
+	}
+	checkResponseCode(t, http.StatusBadRequest, fromGuesser("ApiKey wrong"))
+	checkResponseCode(t, http.StatusBadRequest, fromGuesser("ApiKey wrong"))
+	checkResponseCode(t, http.StatusTooManyRequests, fromGuesser("ApiKey wrong"))
+	checkResponseCode(t, http.StatusTooManyRequests, fromGuesser(apiKey))
+}
+
+func TestClientIPBehindProxy(t *testing.T) {
+	queries := database.New(db)
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{
+		"login": {Requests: 1, Period: time.Hour},
+	})
+	proxied := &http.Server{
+		Handler: routes.GetRouter(handlers.ApiConfig{DB: queries, Conn: db, RateLimiter: limiter, TrustProxyHeaders: true}),
+	}
+	login := func(header, value string) int {
+		req, _ := http.NewRequest(http.MethodPost, "/v1/login", strings.NewReader(`{"name": "Nobody", "password": "wrong"}`))
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set(header, value)
+		return executeRequest(req, proxied).Code
+	}
+	checkResponseCode(t, http.StatusUnauthorized, login("X-Forwarded-For", "198.51.100.1"))
+	checkResponseCode(t, http.StatusTooManyRequests, login("X-Forwarded-For", "198.51.100.1"))
+	// Clients behind the same proxy have their own budget.
+	checkResponseCode(t, http.StatusUnauthorized, login("X-Forwarded-For", "198.51.100.2"))
+	checkResponseCode(t, http.StatusUnauthorized, login("Forwarded", `for="[2001:db8::1]:4711";proto=https`))
+	// Addresses made up by the client are left of the one the proxy saw,
+	// internal proxies right of it.
+	checkResponseCode(t, http.StatusTooManyRequests, login("X-Forwarded-For", "203.0.113.9, 198.51.100.1"))
+	checkResponseCode(t, http.StatusTooManyRequests, login("X-Forwarded-For", "198.51.100.2, 10.0.0.5"))
+	checkResponseCode(t, http.StatusTooManyRequests, login("Forwarded", "for=192.0.2.1, for=2001:db8::1"))
+}
+
+func TestFeverAfterRename(t *testing.T) {
+	response := executeRequest(createUser("Renamed"), server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	renamed := models.UserWithApiKey{}
+	json.Unmarshal(response.Body.Bytes(), &renamed)
+	feverAuth := func(name, key string) string {
+		feverKey := fmt.Sprintf("%x", md5.Sum([]byte(name+":"+key)))
+		req, _ := http.NewRequest(http.MethodPost, "/fever/?api", strings.NewReader("api_key="+feverKey))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		response := executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		return response.Body.String()
+	}
+	assert.Contains(t, feverAuth("Renamed", renamed.APIKey), `"auth":1`)
+
+	req, _ := http.NewRequest(http.MethodPatch, "/v1/users", strings.NewReader(`{"name": "Rebranded"}`))
+	req.Header.Add("Authorization", "ApiKey "+renamed.APIKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	updated := models.UpdatedUser{}
+	json.Unmarshal(response.Body.Bytes(), &updated)
+	assert.Contains(t, updated.Warning, "Renamed")
+	assert.Contains(t, feverAuth("Renamed", renamed.APIKey), `"auth":1`)
+	assert.Contains(t, feverAuth("Rebranded", renamed.APIKey), `"auth":0`)
+
+	req, _ = http.NewRequest(http.MethodPost, "/v1/api_keys/rotate", nil)
+	req.Header.Add("Authorization", "ApiKey "+renamed.APIKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	rotated := models.CreatedApiKey{}
+	json.Unmarshal(response.Body.Bytes(), &rotated)
+	assert.Contains(t, feverAuth("Rebranded", rotated.Key), `"auth":1`)
+}
+
+func TestAccountLifecycle(t *testing.T) {
+	response := executeRequest(createUser("Leaver"), server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	leaver := models.UserWithApiKey{}
+	json.Unmarshal(response.Body.Bytes(), &leaver)
+	leaverRequest := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Add("Authorization", "ApiKey "+leaver.APIKey)
+		return executeRequest(req, server)
+	}
+
+	response = leaverRequest(http.MethodPatch, "/v1/users", `{"name": "Departing"}`)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"name":"Departing"`)
+	checkResponseCode(t, http.StatusBadRequest, leaverRequest(http.MethodPatch, "/v1/users", `{"name": " "}`).Code)
+
+	createFeed := func(name, url string) models.Feed {
+		response := leaverRequest(http.MethodPost, "/v1/feeds", fmt.Sprintf(`{"name": %q, "url": %q}`, name, url))
+		checkResponseCode(t, http.StatusCreated, response.Code)
+		created := models.Feed{}
+		json.Unmarshal(response.Body.Bytes(), &created)
+		return created
+	}
+	shared := createFeed("Shared", "https://example.com/shared.xml")
+	private := createFeed("Private", "https://example.com/private.xml")
+	checkResponseCode(t, http.StatusCreated, leaverRequest(http.MethodPost, "/v1/feed_follows", fmt.Sprintf(`{"feed_id": %q}`, private.ID)).Code)
+	req, _ := http.NewRequest(http.MethodPost, "/v1/feed_follows", strings.NewReader(fmt.Sprintf(`{"feed_id": %q}`, shared.ID)))
+	req.Header.Add("Authorization", apiKey)
+	checkResponseCode(t, http.StatusCreated, executeRequest(req, server).Code)
+
+	response = leaverRequest(http.MethodGet, "/v1/users/export", "")
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/zip", response.Header().Get("Content-Type"))
+	archive, err := zip.NewReader(bytes.NewReader(response.Body.Bytes()), int64(response.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(data)
+	}
+	assert.Contains(t, contents["profile.json"], "Departing")
+	assert.Contains(t, contents["feed_follows.json"], private.ID.String())
+	assert.Contains(t, contents["created_feeds.json"], shared.ID.String())
+	assert.Contains(t, contents["subscriptions.opml"], `xmlUrl="https://example.com/private.xml"`)
+	assert.Contains(t, contents, "post_states.json")
+
+	checkResponseCode(t, http.StatusNoContent, leaverRequest(http.MethodDelete, "/v1/users", "").Code)
+	checkResponseCode(t, http.StatusBadRequest, leaverRequest(http.MethodGet, "/v1/users", "").Code)
+
+	// The feed Luis follows outlives its creator, the other one is gone.
+	var owner uuid.NullUUID
+	err = db.QueryRow("SELECT user_id FROM feeds WHERE id = $1", shared.ID).Scan(&owner)
+	assert.NoError(t, err)
+	assert.False(t, owner.Valid)
+	err = db.QueryRow("SELECT user_id FROM feeds WHERE id = $1", private.ID).Scan(&owner)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestSignup(t *testing.T) {
+	queries := database.New(db)
+	signupServer := func(signup auth.SignupConfig) *http.Server {
+		return &http.Server{
+			Handler: routes.GetRouter(handlers.ApiConfig{DB: queries, Conn: db, Signup: signup}),
+		}
+	}
+	signUp := func(s *http.Server, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+		return executeRequest(req, s)
+	}
+	checkResponseCode(t, http.StatusConflict, executeRequest(createUser("LUIS"), server).Code)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(createUser("  "), server).Code)
+	checkResponseCode(t, http.StatusBadRequest, signUp(server, fmt.Sprintf(`{"name": %q}`, strings.Repeat("a", 65))).Code)
+
+	closed := signupServer(auth.SignupConfig{Mode: auth.SignupAdmin})
+	checkResponseCode(t, http.StatusForbidden, executeRequest(createUser("Walk-in"), closed).Code)
+	req, _ := http.NewRequest(http.MethodPost, "/v1/admin/users", strings.NewReader(`{"name": "Walk-in"}`))
+	req.Header.Add("Authorization", apiKey)
+	checkResponseCode(t, http.StatusCreated, executeRequest(req, closed).Code)
+
+	invite := signupServer(auth.SignupConfig{Mode: auth.SignupInvite, ProofOfWorkBits: 8})
+	checkResponseCode(t, http.StatusForbidden, executeRequest(createUser("Guest"), invite).Code)
+	req, _ = http.NewRequest(http.MethodPost, "/v1/admin/invitations", nil)
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, invite)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	invitation := models.CreatedInvitation{}
+	json.Unmarshal(response.Body.Bytes(), &invitation)
+	assert.NotEmpty(t, invitation.Code)
+
+	solve := func() (string, string) {
+		req, _ := http.NewRequest(http.MethodPost, "/v1/users/challenge", nil)
+		response := executeRequest(req, invite)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+		challenge := models.SignupChallenge{}
+		json.Unmarshal(response.Body.Bytes(), &challenge)
+		nonce := 0
+		for !auth.CheckProofOfWork(challenge.Challenge, fmt.Sprint(nonce), challenge.Difficulty) {
+			nonce++
+		}
+		return challenge.Challenge, fmt.Sprint(nonce)
+	}
+	challenge, nonce := solve()
+	body := fmt.Sprintf(`{"name": "Guest", "invitation": %q, "challenge": %q, "nonce": %q}`, invitation.Code, challenge, nonce)
+	checkResponseCode(t, http.StatusCreated, signUp(invite, body).Code)
+	checkResponseCode(t, http.StatusForbidden, signUp(invite, body).Code)
+
+	challenge, nonce = solve()
+	body = fmt.Sprintf(`{"name": "Second guest", "invitation": %q, "challenge": %q, "nonce": %q}`, invitation.Code, challenge, nonce)
+	checkResponseCode(t, http.StatusForbidden, signUp(invite, body).Code)
+	var count int
+	db.QueryRow("SELECT count(*) FROM users WHERE name = 'Second guest'").Scan(&count)
+	assert.Zero(t, count)
+
+	// A rejected signup leaves the challenge for another try.
+	req, _ = http.NewRequest(http.MethodPost, "/v1/admin/invitations", nil)
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, invite)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	json.Unmarshal(response.Body.Bytes(), &invitation)
+	body = fmt.Sprintf(`{"name": "Second guest", "invitation": %q, "challenge": %q, "nonce": %q}`, invitation.Code, challenge, nonce)
+	checkResponseCode(t, http.StatusCreated, signUp(invite, body).Code)
+}
+
+func TestPostAttachments(t *testing.T) {
+	queries := database.New(db)
+	post, err := queries.CreatePost(context.Background(), database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+		Title:       "Episode 1",
+		PublishedAt: time.Now().UTC(),
+		Url:         "https://example.com/episode-1",
+		FeedID:      feed.ID,
+		Episode:     sql.NullInt32{Int32: 1, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = queries.CreatePostAttachment(context.Background(), database.CreatePostAttachmentParams{
+		ID:              uuid.New(),
+		CreatedAt:       time.Now().UTC(),
+		PostID:          post.ID,
+		Kind:            "enclosure",
+		Url:             "https://example.com/episode-1.mp3",
+		MimeType:        sql.NullString{String: "audio/mpeg", Valid: true},
+		DurationSeconds: sql.NullInt32{Int32: 1800, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "/v1/posts", nil)
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	page := handlers.WrappedSlice[models.Post]{}
+	json.Unmarshal(response.Body.Bytes(), &page)
+	for _, got := range page.Results {
+		if got.ID != post.ID {
+			assert.NotNil(t, got.Attachments)
+			continue
+		}
+		assert.Equal(t, int32(1), *got.Episode)
+		assert.Nil(t, got.Season)
+		if assert.Len(t, got.Attachments, 1) {
+			assert.Equal(t, "https://example.com/episode-1.mp3", got.Attachments[0].Url)
+			assert.Equal(t, int32(1800), *got.Attachments[0].DurationSeconds)
+		}
+	}
+	assert.Contains(t, response.Body.String(), post.ID.String())
+}
+
+func TestPostAuthorsAndCategories(t *testing.T) {
+	queries := database.New(db)
+	post, err := queries.CreatePost(context.Background(), database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+		Title:       "Long read",
+		Description: sql.NullString{String: "Teaser", Valid: true},
+		PublishedAt: time.Now().UTC(),
+		Url:         "https://example.com/long-read",
+		FeedID:      feed.ID,
+		Content:     sql.NullString{String: "The whole story", Valid: true},
+		Authors:     []string{"Ada Lovelace", "Grace Hopper"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = queries.CreatePostCategory(context.Background(), database.CreatePostCategoryParams{PostID: post.ID, Name: "Computing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := func(query string) handlers.WrappedSlice[models.Post] {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/post?"+query, nil)
+		req.Header.Add("Authorization", apiKey)
+		response := executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		page := handlers.WrappedSlice[models.Post]{}
+		json.Unmarshal(response.Body.Bytes(), &page)
+		return page
+	}
+	page := filter("author=hopper")
+	if assert.Len(t, page.Results, 1) {
+		got := page.Results[0]
+		assert.Equal(t, post.ID, got.ID)
+		assert.Equal(t, "The whole story", got.Content)
+		assert.Equal(t, []string{"Ada Lovelace", "Grace Hopper"}, got.Authors)
+		assert.Equal(t, []string{"Computing"}, got.Categories)
+	}
+	page = filter("category=computing")
+	if assert.Len(t, page.Results, 1) {
+		assert.Equal(t, post.ID, page.Results[0].ID)
+	}
+	assert.Empty(t, filter("category=comput").Results)
+	assert.Empty(t, filter("author=turing").Results)
+}
+
+func TestLegacyPostsAreSanitized(t *testing.T) {
+	queries := database.New(db)
+	post, err := queries.CreatePost(context.Background(), database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+		Title:       "Stored raw",
+		Description: sql.NullString{String: `<p>Hi<script>alert(1)</script><img src="/a.png"></p>`, Valid: true},
+		PublishedAt: time.Now().UTC(),
+		Url:         "https://example.com/raw/",
+		FeedID:      feed.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "/v1/post?title=Stored+raw", nil)
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	page := handlers.WrappedSlice[models.Post]{}
+	json.Unmarshal(response.Body.Bytes(), &page)
+	if assert.Len(t, page.Results, 1) {
+		assert.Equal(t, post.ID, page.Results[0].ID)
+		assert.Equal(t, `<p>Hi<img src="https://example.com/a.png"/></p>`, page.Results[0].Description)
+		assert.Equal(t, "Hi", page.Results[0].DescriptionText)
+	}
+
+	// Fever and Google Reader clients get the sanitized markup too.
+	feverKey := fmt.Sprintf("%x", md5.Sum([]byte("Luis:"+strings.TrimPrefix(apiKey, "ApiKey "))))
+	form := fmt.Sprintf("api_key=%s", feverKey)
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/fever/?api&items&with_ids=%d", post.ShortID), strings.NewReader(form))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "https://example.com/a.png")
+	assert.NotContains(t, response.Body.String(), "alert")
+
+	form = "Email=Luis&Passwd=" + strings.TrimPrefix(apiKey, "ApiKey ")
+	req, _ = http.NewRequest(http.MethodPost, "/api/greader/accounts/ClientLogin", strings.NewReader(form))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	_, token, _ := strings.Cut(response.Body.String(), "Auth=")
+	req, _ = http.NewRequest(http.MethodPost, "/api/greader/reader/api/0/stream/items/contents", strings.NewReader(fmt.Sprintf("i=%d", post.ShortID)))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Authorization", "GoogleLogin auth="+strings.TrimSpace(token))
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "https://example.com/a.png")
+	assert.NotContains(t, response.Body.String(), "alert")
+}
+
+func TestExtractFullContent(t *testing.T) {
+	queries := database.New(db)
+	article := strings.Repeat("<p>The rest of the story, long enough for the extraction to tell it apart from the page around it, with commas, clauses, and detail.</p>", 8)
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><body><nav><a href="/">Home</a></nav><article><h1>Teaser</h1>%s</article><footer>Copyright</footer></body></html>`, article)
+	}))
+	defer page.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, "/v1/feed_follows", nil)
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	follows := handlers.WrappedSlice[models.FeedFollow]{}
+	json.Unmarshal(response.Body.Bytes(), &follows)
+	var followID uuid.UUID
+	for _, follow := range follows.Results {
+		if follow.FeedID == feed.ID {
+			followID = follow.ID
+		}
+	}
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("/v1/feed_follows/%s", followID), strings.NewReader(`{"extract_full_content": true}`))
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	follow := models.FeedFollow{}
+	json.Unmarshal(response.Body.Bytes(), &follow)
+	assert.True(t, follow.ExtractFullContent)
+	wants, err := queries.FeedWantsFullContent(context.Background(), feed.ID)
+	assert.NoError(t, err)
+	assert.True(t, wants)
+
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("/v1/feed_follows/%s", uuid.New()), strings.NewReader(`{"extract_full_content": true}`))
+	req.Header.Add("Authorization", apiKey)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, server).Code)
+
+	post, err := queries.CreatePost(context.Background(), database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+		Title:       "Teaser",
+		Description: sql.NullString{String: "<p>Read more</p>", Valid: true},
+		PublishedAt: time.Now().UTC(),
+		Url:         page.URL + "/teaser",
+		FeedID:      feed.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/v1/posts/%s/extract", post.ID), nil)
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	extracted := models.Post{}
+	json.Unmarshal(response.Body.Bytes(), &extracted)
+	assert.Equal(t, "<p>Read more</p>", extracted.Description)
+	assert.Contains(t, extracted.ExtractedContent, "The rest of the story")
+	assert.NotContains(t, extracted.ExtractedContent, "Copyright")
+	assert.NotNil(t, extracted.ExtractedAt)
+
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/v1/posts/%s/extract", uuid.New()), nil)
+	req.Header.Add("Authorization", apiKey)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, server).Code)
+
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("/v1/feed_follows/%s", followID), strings.NewReader(`{"extract_full_content": false}`))
+	req.Header.Add("Authorization", apiKey)
+	checkResponseCode(t, http.StatusOK, executeRequest(req, server).Code)
+
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/v1/posts/%s/extract", post.ID), nil)
+	req.Header.Add("Authorization", apiKey)
+	checkResponseCode(t, http.StatusForbidden, executeRequest(req, server).Code)
+}
+
+func TestValidateFeed(t *testing.T) {
+	feeds := map[string]string{
+		"/valid.xml":     `<?xml version="1.0" encoding="UTF-8"?><rss><channel><item><title>Fine</title></item></channel></rss>`,
+		"/malformed.xml": `<rss><channel><item><title>Salt & Pepper&nbsp;</title></item><item><title>Cut`,
+		"/broken.xml":    `<html><body>Not a feed</body></html>`,
+	}
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, feeds[r.URL.Path])
+	}))
+	defer feedServer.Close()
+
+	validate := func(path string) models.FeedValidation {
+		req, _ := http.NewRequest(http.MethodPost, "/v1/feeds/validate", strings.NewReader(fmt.Sprintf(`{"url": %q}`, feedServer.URL+path)))
+		req.Header.Add("Authorization", apiKey)
+		response := executeRequest(req, server)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		validation := models.FeedValidation{}
+		json.Unmarshal(response.Body.Bytes(), &validation)
+		return validation
+	}
+	valid := validate("/valid.xml")
+	assert.True(t, valid.Valid)
+	assert.Nil(t, valid.Error)
+	assert.Equal(t, 1, valid.Items)
+
+	malformed := validate("/malformed.xml")
+	assert.False(t, malformed.Valid)
+	assert.NotNil(t, malformed.Error)
+	assert.True(t, malformed.Recoverable)
+	assert.Equal(t, 1, malformed.Items)
+
+	broken := validate("/broken.xml")
+	assert.False(t, broken.Valid)
+	assert.False(t, broken.Recoverable)
+}
+
+func TestScrapedFeeds(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><body>
+			<div class="entry"><a class="title" href="/status/1">Degraded API</a><time datetime="2024-01-02T03:04:05Z"></time><p>Investigating</p></div>
+			<div class="entry"><a class="title" href="/status/2">Resolved</a></div>
+		</body></html>`)
+	}))
+	defer page.Close()
+	config := `{"item": ".entry", "title": ".title", "date": "time", "summary": "p"}`
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/feeds/preview", strings.NewReader(fmt.Sprintf(`{"url": %q, "kind": "scraped", "scrape_config": %s}`, page.URL, config)))
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	preview := handlers.WrappedSlice[models.FeedPreviewItem]{}
+	json.Unmarshal(response.Body.Bytes(), &preview)
+	if assert.Len(t, preview.Results, 2) {
+		assert.Equal(t, "Degraded API", preview.Results[0].Title)
+		assert.Equal(t, page.URL+"/status/1", preview.Results[0].Url)
+		assert.Equal(t, "<p>Investigating</p>", preview.Results[0].Description)
+		assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), preview.Results[0].PublishedAt.UTC())
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/v1/feeds/preview", strings.NewReader(fmt.Sprintf(`{"url": %q, "kind": "scraped", "scrape_config": {"title": "h1"}}`, page.URL)))
+	req.Header.Add("Authorization", apiKey)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req, server).Code)
+
+	req, _ = http.NewRequest(http.MethodPost, "/v1/feeds", strings.NewReader(fmt.Sprintf(`{"name": "Status page", "url": %q, "kind": "scraped", "scrape_config": %s}`, page.URL, config)))
+	req.Header.Add("Authorization", apiKey)
+	response = executeRequest(req, server)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	created := models.Feed{}
+	json.Unmarshal(response.Body.Bytes(), &created)
+	assert.Equal(t, "scraped", created.Kind)
+	assert.JSONEq(t, `{"item": ".entry", "title": ".title", "link": "", "date": "time", "date_format": "", "summary": "p"}`, string(created.ScrapeConfig))
+
+	req, _ = http.NewRequest(http.MethodPost, "/v1/feeds", strings.NewReader(`{"name": "Unknown", "url": "https://example.com/unknown", "kind": "atom"}`))
+	req.Header.Add("Authorization", apiKey)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req, server).Code)
+}
+
+func TestWebSub(t *testing.T) {
+	queries := database.New(db)
+	pushServer := &http.Server{
+		Handler: routes.GetRouter(handlers.ApiConfig{
+			DB:     queries,
+			Conn:   db,
+			Fetch:  localFetch,
+			WebSub: websub.NewSubscriber(websub.Config{CallbackURL: "https://reader.example.com/websub", LeaseSeconds: 3600}, localFetch),
+		}),
+	}
+	pushed, err := queries.CreateFeed(context.Background(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Name:      "Pushed",
+		Url:       "https://example.com/pushed.xml",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = queries.SubscribeWebSub(context.Background(), database.SubscribeWebSubParams{
+		FeedID: pushed.ID,
+		Hub:    "https://hub.example.com/",
+		Topic:  pushed.Url,
+		Secret: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := fmt.Sprintf("/websub/%s", pushed.ID)
+
+	req, _ := http.NewRequest(http.MethodGet, callback+"?hub.mode=subscribe&hub.topic=https://example.com/other.xml&hub.challenge=abc", nil)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, pushServer).Code)
+	req, _ = http.NewRequest(http.MethodGet, callback+"?hub.mode=subscribe&hub.topic=https://example.com/pushed.xml&hub.challenge=abc", nil)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, server).Code)
+	req, _ = http.NewRequest(http.MethodGet, callback+"?hub.mode=subscribe&hub.topic=https://example.com/pushed.xml&hub.challenge=abc&hub.lease_seconds=600", nil)
+	response := executeRequest(req, pushServer)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Equal(t, "abc", response.Body.String())
+	subscription, err := queries.GetWebSubSubscription(context.Background(), pushed.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "active", subscription.State)
+	assert.WithinDuration(t, time.Now().UTC().Add(600*time.Second), subscription.LeaseExpiresAt.Time, time.Minute)
+	// The request was verified already, a replay is refused.
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, pushServer).Code)
+
+	toFetch, err := queries.GetNextFeedsToFetch(context.Background(), 1000)
+	assert.NoError(t, err)
+	for _, feed := range toFetch {
+		assert.NotEqual(t, pushed.ID, feed.ID)
+	}
+
+	content := []byte(`<rss><channel><item><title>Pushed post</title><link>https://example.com/pushed/1</link></item></channel></rss>`)
+	push := func(signature string) int {
+		req, _ := http.NewRequest(http.MethodPost, callback, bytes.NewReader(content))
+		req.Header.Set("Content-Type", "application/rss+xml")
+		req.Header.Set("X-Hub-Signature", signature)
+		return executeRequest(req, pushServer).Code
+	}
+	var count int
+	checkResponseCode(t, http.StatusAccepted, push("sha256=00"))
+	db.QueryRow("SELECT COUNT(*) FROM posts WHERE url = 'https://example.com/pushed/1'").Scan(&count)
+	assert.Equal(t, 0, count)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(content)
+	checkResponseCode(t, http.StatusAccepted, push("sha256="+hex.EncodeToString(mac.Sum(nil))))
+	db.QueryRow("SELECT COUNT(*) FROM posts WHERE url = 'https://example.com/pushed/1' AND feed_id = $1", pushed.ID).Scan(&count)
+	assert.Equal(t, 1, count)
+	subscription, err = queries.GetWebSubSubscription(context.Background(), pushed.ID)
+	assert.NoError(t, err)
+	assert.True(t, subscription.LastPushAt.Valid)
+
+	deny, _ := http.NewRequest(http.MethodGet, callback+"?hub.mode=denied&hub.topic=https://example.com/pushed.xml&hub.reason=gone", nil)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(deny, pushServer).Code)
+
+	// Hubs don't get to grant more than the lease asked for.
+	resubscribe := func() {
+		_, err := queries.SubscribeWebSub(context.Background(), database.SubscribeWebSubParams{
+			FeedID: pushed.ID,
+			Hub:    "https://hub.example.com/",
+			Topic:  pushed.Url,
+			Secret: "secret",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	resubscribe()
+	req, _ = http.NewRequest(http.MethodGet, callback+"?hub.mode=subscribe&hub.topic=https://example.com/pushed.xml&hub.challenge=abc&hub.lease_seconds=999999999", nil)
+	checkResponseCode(t, http.StatusOK, executeRequest(req, pushServer).Code)
+	subscription, err = queries.GetWebSubSubscription(context.Background(), pushed.ID)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().UTC().Add(3600*time.Second), subscription.LeaseExpiresAt.Time, time.Minute)
+
+	// Nor to verify a request long after it was made.
+	resubscribe()
+	db.Exec("UPDATE websub_subscriptions SET requested_at = requested_at - INTERVAL '2 hours' WHERE feed_id = $1", pushed.ID)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, pushServer).Code)
+
+	resubscribe()
+	checkResponseCode(t, http.StatusOK, executeRequest(deny, pushServer).Code)
+	subscription, err = queries.GetWebSubSubscription(context.Background(), pushed.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "denied", subscription.State)
+}
+
+func TestFetchPrivateNetworks(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel><title>Internal</title></channel></rss>`))
+	}))
+	defer internal.Close()
+	guarded := &http.Server{
+		Handler: routes.GetRouter(handlers.ApiConfig{DB: database.New(db), Conn: db}),
+	}
+
+	for _, path := range []string{"/v1/feeds/validate", "/v1/feeds/preview"} {
+		req, _ := http.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(`{"url": "`+internal.URL+`"}`)))
+		req.Header.Add("Authorization", apiKey)
+		response := executeRequest(req, guarded)
+		checkResponseCode(t, http.StatusBadGateway, response.Code)
+		assert.Contains(t, response.Body.String(), "private network")
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/feeds/validate", bytes.NewReader([]byte(`{"url": "`+internal.URL+`"}`)))
+	req.Header.Add("Authorization", apiKey)
+	response := executeRequest(req, server)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"valid":true`)
+}
+
 func executeRequest(req *http.Request, s *http.Server) *httptest.ResponseRecorder {
     rr := httptest.NewRecorder()
 	s.Handler.ServeHTTP(rr, req)
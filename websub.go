@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leguzman/rss-project/internal/database"
+	"github.com/leguzman/rss-project/internal/websub"
+)
+
+// How long to wait before asking a hub again after it didn't verify a
+// subscription, or denied it.
+const (
+	webSubPendingRetry = websub.VerifyTimeout
+	webSubDeniedRetry  = 24 * time.Hour
+)
+
+// webSubStore keeps the WebSub subscriptions, implemented by
+// *database.Queries.
+type webSubStore interface {
+	GetWebSubSubscription(ctx context.Context, feedID uuid.UUID) (database.WebsubSubscription, error)
+	SubscribeWebSub(ctx context.Context, arg database.SubscribeWebSubParams) (database.WebsubSubscription, error)
+	GetWebSubSubscriptionsToRenew(ctx context.Context) ([]database.WebsubSubscription, error)
+	MarkWebSubRenewalRequested(ctx context.Context, feedID uuid.UUID) error
+}
+
+// subscribeWebSub subscribes to a feed at the hub it advertises, unless it
+// is already or the hub hasn't answered the last request yet.
+func subscribeWebSub(subscriber *websub.Subscriber, db webSubStore, feed database.Feed, hub, topic string) {
+	ctx := context.Background()
+	subscription, err := db.GetWebSubSubscription(ctx, feed.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Println("Couldn't get WebSub subscription: ", err)
+		return
+	}
+	if err == nil && subscription.Hub == hub && subscription.Topic == topic {
+		now := time.Now().UTC()
+		switch subscription.State {
+		case "active":
+			// renewWebSubLeases asks again before the lease runs out.
+			if subscription.LeaseExpiresAt.Time.After(now) {
+				return
+			}
+		case "pending":
+			if subscription.RequestedAt.Add(webSubPendingRetry).After(now) {
+				return
+			}
+		case "denied":
+			if subscription.UpdatedAt.Add(webSubDeniedRetry).After(now) {
+				return
+			}
+		}
+	}
+	// Content pushed under the current subscription is still signed with
+	// its secret, so it's only replaced along with the hub or topic.
+	var secret string
+	if err == nil && subscription.Hub == hub && subscription.Topic == topic {
+		secret = subscription.Secret
+	} else {
+		secret, err = websub.GenerateSecret()
+		if err != nil {
+			log.Println("Couldn't generate WebSub secret: ", err)
+			return
+		}
+	}
+	_, err = db.SubscribeWebSub(ctx, database.SubscribeWebSubParams{
+		FeedID: feed.ID,
+		Hub:    hub,
+		Topic:  topic,
+		Secret: secret,
+	})
+	if err != nil {
+		log.Println("Couldn't store WebSub subscription: ", err)
+		return
+	}
+	err = subscriber.Subscribe(ctx, hub, topic, feed.ID, secret)
+	if err != nil {
+		log.Printf("Couldn't subscribe to %s at %s: %v", topic, hub, err)
+		return
+	}
+	log.Printf("Subscribed to %s at %s", topic, hub)
+}
+
+// renewWebSubLeases asks hubs to extend subscriptions about to run out.
+// Feeds whose renewal fails are polled again once the lease is over.
+func renewWebSubLeases(subscriber *websub.Subscriber, db webSubStore) {
+	ctx := context.Background()
+	subscriptions, err := db.GetWebSubSubscriptionsToRenew(ctx)
+	if err != nil {
+		log.Println("Couldn't get WebSub subscriptions to renew: ", err)
+		return
+	}
+	for _, subscription := range subscriptions {
+		err = db.MarkWebSubRenewalRequested(ctx, subscription.FeedID)
+		if err != nil {
+			log.Println("Couldn't mark WebSub renewal: ", err)
+			continue
+		}
+		err = subscriber.Subscribe(ctx, subscription.Hub, subscription.Topic, subscription.FeedID, subscription.Secret)
+		if err != nil {
+			log.Printf("Couldn't renew subscription to %s at %s: %v", subscription.Topic, subscription.Hub, err)
+		}
+	}
+}